@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// BatchQuery набор запросов, которые отправляются серверу одним пакетом (SendBatch).
+// Запросы добавляются через BatchStructs и BatchOne, каждый со своим приемником результата
+type BatchQuery struct {
+	batch pgx.Batch
+}
+
+// NewBatchQuery создает пустой пакет запросов
+func NewBatchQuery() *BatchQuery {
+	return &BatchQuery{}
+}
+
+// Len возвращает количество запросов в пакете
+func (b *BatchQuery) Len() int {
+	return b.batch.Len()
+}
+
+// BatchStructs добавляет в пакет запрос, строки которого попадут в dest (структуры или простые типы)
+func BatchStructs[T any](b *BatchQuery, dest *[]T, sql string, args ...any) {
+	b.batch.Queue(sql, args...).Query(func(rows pgx.Rows) error {
+		result, err := pgx.CollectRows(rows, rowToAuto[T]())
+		if err != nil {
+			return fmt.Errorf("%s: %w", sql, err)
+		}
+		*dest = result
+		return nil
+	})
+}
+
+// BatchOne добавляет в пакет запрос, единственное значение которого будет записано в dest
+func BatchOne[T any](b *BatchQuery, dest *T, sql string, args ...any) {
+	b.batch.Queue(sql, args...).QueryRow(func(row pgx.Row) error {
+		if err := row.Scan(dest); err != nil {
+			return fmt.Errorf("%s: %w", sql, err)
+		}
+		return nil
+	})
+}
+
+// batchSender реализуют *pgxpool.Pool, *pgxpool.Conn, *pgx.Conn и pgx.Tx
+type batchSender interface {
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// Run отправляет все запросы пакета за один обмен с сервером и заполняет приемники.
+// Возвращается первая ошибка; db должен поддерживать SendBatch
+func (b *BatchQuery) Run(ctx context.Context, db Querier) (err error) {
+	sender, ok := db.(batchSender)
+	if !ok {
+		return fmt.Errorf("%T does not support batches", db)
+	}
+
+	ctx, finish, err := startQuery(ctx, db, "BatchQuery", "BATCH")
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed batch of %d queries in %s", b.batch.Len(), elapsed)
+	}()
+
+	if err = sender.SendBatch(ctx, &b.batch).Close(); err != nil {
+		return fmt.Errorf("batch failed: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBatchQueryQueuesStatements(t *testing.T) {
+	var ids []int
+	var total int64
+
+	b := NewBatchQuery()
+	BatchStructs(b, &ids, "SELECT id FROM users WHERE active = $1", true)
+	BatchOne(b, &total, "SELECT count(*) FROM orders")
+	if b.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", b.Len())
+	}
+
+	db := &fakeQuerier{}
+	if err := b.Run(context.Background(), &fakeTx{q: db}); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := []fakeCall{
+		{sql: "SELECT id FROM users WHERE active = $1", args: []any{true}},
+		{sql: "SELECT count(*) FROM orders"},
+	}
+	if !reflect.DeepEqual(db.calls, want) {
+		t.Fatalf("calls = %v, want %v", db.calls, want)
+	}
+}
+
+func TestBatchQueryErrors(t *testing.T) {
+	ctx := context.Background()
+	var n int
+
+	b := NewBatchQuery()
+	BatchOne(b, &n, "SELECT 1")
+	if err := b.Run(ctx, &fakeQuerier{}); err == nil {
+		t.Fatal("Run() error = nil for a querier without batch support")
+	}
+
+	errBroken := errors.New("broken")
+	db := &fakeQuerier{err: errBroken}
+	if err := b.Run(ctx, &fakeTx{q: db}); !errors.Is(err, errBroken) {
+		t.Fatalf("Run() error = %v, want %v", err, errBroken)
+	}
+}
+
+func TestBatchQuery(t *testing.T) {
+	type user struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int, name text")
+	mustExec(t, pool, "INSERT INTO "+table+" VALUES (1, 'Ann'), (2, 'Bob')")
+
+	var users []user
+	var ids []int
+	var count int64
+
+	b := NewBatchQuery()
+	BatchStructs(b, &users, "SELECT id, name FROM "+table+" ORDER BY id")
+	BatchStructs(b, &ids, "SELECT id FROM "+table+" WHERE id > $1", 1)
+	BatchOne(b, &count, "SELECT count(*) FROM "+table)
+	if err := b.Run(ctx, pool); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if want := []user{{1, "Ann"}, {2, "Bob"}}; !reflect.DeepEqual(users, want) {
+		t.Fatalf("users = %+v, want %+v", users, want)
+	}
+	if want := []int{2}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	if count != 2 {
+		t.Fatalf("count = %d, want 2", count)
+	}
+
+	b = NewBatchQuery()
+	BatchOne(b, &count, "SELECT no_such_column FROM "+table)
+	if err := b.Run(ctx, pool); err == nil {
+		t.Fatal("Run() error = nil for an invalid query")
+	}
+}
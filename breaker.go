@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается без обращения к базе, пока размыкатель пула открыт
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreakerConfig настройки размыкателя: после Failures подряд ошибок базы в пределах Window
+// запросы отклоняются в течение Cooldown, затем пропускается один пробный запрос
+type CircuitBreakerConfig struct {
+	Failures int
+	Window   time.Duration
+	Cooldown time.Duration
+}
+
+// breakers размыкатели по пулам; по умолчанию для пула размыкатель не задан
+var breakers sync.Map
+
+// EnableCircuitBreaker включает размыкатель для пула. Он действует на хелперы, вызванные с самим пулом,
+// с *DB поверх него и с транзакциями, открытыми хелперами (WithTransaction и другие)
+func EnableCircuitBreaker(pool *pgxpool.Pool, cfg CircuitBreakerConfig) {
+	breakers.Store(pool, &circuitBreaker{cfg: cfg})
+}
+
+// DisableCircuitBreaker отключает размыкатель для пула
+func DisableCircuitBreaker(pool *pgxpool.Pool) {
+	breakers.Delete(pool)
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+type circuitBreaker struct {
+	mu           sync.Mutex
+	cfg          CircuitBreakerConfig
+	state        breakerState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+}
+
+// allow решает, можно ли выполнить запрос; после Cooldown пропускает единственный пробный запрос,
+// для которого возвращает probe = true
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cfg.Cooldown {
+			return false, ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		return true, nil
+	case breakerHalfOpen:
+		return false, ErrCircuitOpen
+	default:
+		return false, nil
+	}
+}
+
+// record учитывает результат запроса. Пока размыкатель открыт или ждет пробный запрос, результаты запросов,
+// начатых до размыкания, игнорируются: закрыть его может только пробный запрос
+func (b *circuitBreaker) record(err error, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failed := isDatabaseFailure(err)
+	now := time.Now()
+
+	switch {
+	case b.state == breakerHalfOpen && probe:
+		if failed {
+			b.state = breakerOpen
+			b.openedAt = now
+			return
+		}
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	case b.state != breakerClosed:
+		return
+	case !failed:
+		b.failures = 0
+		return
+	}
+
+	if b.failures == 0 || now.Sub(b.firstFailure) > b.cfg.Window {
+		b.failures = 0
+		b.firstFailure = now
+	}
+
+	b.failures++
+	if b.failures >= b.cfg.Failures {
+		b.state = breakerOpen
+		b.openedAt = now
+		b.failures = 0
+	}
+}
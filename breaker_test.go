@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"io"
+	"testing"
+	"time"
+)
+
+func openBreaker(t *testing.T, b *circuitBreaker) {
+	t.Helper()
+
+	for i := 0; i < b.cfg.Failures; i++ {
+		if _, err := b.allow(); err != nil {
+			t.Fatalf("allow() error = %v before reaching the failure threshold", err)
+		}
+		b.record(io.ErrUnexpectedEOF, false)
+	}
+
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v, want %v", err, ErrCircuitOpen)
+	}
+}
+
+func TestCircuitBreakerOpensAfterFailures(t *testing.T) {
+	b := &circuitBreaker{cfg: CircuitBreakerConfig{Failures: 3, Window: time.Minute, Cooldown: time.Minute}}
+	openBreaker(t, b)
+}
+
+func TestCircuitBreakerIgnoresQueryErrors(t *testing.T) {
+	b := &circuitBreaker{cfg: CircuitBreakerConfig{Failures: 2, Window: time.Minute, Cooldown: time.Minute}}
+
+	for i := 0; i < 5; i++ {
+		b.record(&pgconn.PgError{Code: CodeUniqueViolation}, false)
+	}
+	if _, err := b.allow(); err != nil {
+		t.Fatalf("allow() error = %v after query errors, want nil", err)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	b := &circuitBreaker{cfg: CircuitBreakerConfig{Failures: 2, Window: time.Minute, Cooldown: time.Minute}}
+
+	b.record(io.EOF, false)
+	b.record(nil, false)
+	b.record(io.EOF, false)
+
+	if _, err := b.allow(); err != nil {
+		t.Fatalf("allow() error = %v, want nil when failures are not consecutive", err)
+	}
+}
+
+func TestCircuitBreakerProbeAfterCooldown(t *testing.T) {
+	b := &circuitBreaker{cfg: CircuitBreakerConfig{Failures: 1, Window: time.Minute, Cooldown: 10 * time.Millisecond}}
+	openBreaker(t, b)
+
+	time.Sleep(20 * time.Millisecond)
+
+	probe, err := b.allow()
+	if err != nil || !probe {
+		t.Fatalf("allow() = %v, %v after cooldown, want probe", probe, err)
+	}
+	if _, err = b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v while the probe is running, want %v", err, ErrCircuitOpen)
+	}
+
+	b.record(nil, true)
+	if _, err = b.allow(); err != nil {
+		t.Fatalf("allow() error = %v after a successful probe, want nil", err)
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	b := &circuitBreaker{cfg: CircuitBreakerConfig{Failures: 1, Window: time.Minute, Cooldown: 10 * time.Millisecond}}
+	openBreaker(t, b)
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := b.allow(); err != nil {
+		t.Fatalf("allow() error = %v after cooldown", err)
+	}
+
+	b.record(io.EOF, true)
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v after a failed probe, want %v", err, ErrCircuitOpen)
+	}
+}
+
+func TestCircuitBreakerIgnoresStaleSuccessWhileOpen(t *testing.T) {
+	b := &circuitBreaker{cfg: CircuitBreakerConfig{Failures: 1, Window: time.Minute, Cooldown: time.Minute}}
+	openBreaker(t, b)
+
+	// запрос, начатый до размыкания, завершился успешно
+	b.record(nil, false)
+
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow() error = %v after a stale success, want %v", err, ErrCircuitOpen)
+	}
+}
+
+// unhashableQuerier Querier, который нельзя использовать ключом map
+type unhashableQuerier struct {
+	*fakeQuerier
+	tags []string
+}
+
+func TestStartQueryWithUnhashableQuerier(t *testing.T) {
+	db := unhashableQuerier{fakeQuerier: &fakeQuerier{}, tags: []string{"a"}}
+
+	if err := Exec(context.Background(), db, "SELECT 1"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+}
+
+func TestCircuitBreakerAppliesToPoolTransactions(t *testing.T) {
+	pool := unreachablePool(t)
+	EnableCircuitBreaker(pool, CircuitBreakerConfig{Failures: 1, Window: time.Minute, Cooldown: time.Minute})
+	defer DisableCircuitBreaker(pool)
+
+	b, _ := breakers.Load(pool)
+	b.(*circuitBreaker).record(io.EOF, false)
+
+	tx := &pooledTx{Tx: &fakeTx{q: &fakeQuerier{}}, pool: pool}
+	if err := Exec(context.Background(), tx, "SELECT 1"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Exec() in a pool transaction error = %v, want %v", err, ErrCircuitOpen)
+	}
+}
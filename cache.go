@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultQueryCacheSize количество результатов, которое по умолчанию хранит кеш QueryStructsCached
+const defaultQueryCacheSize = 1024
+
+type cacheEntry struct {
+	value   any
+	expires time.Time
+}
+
+var (
+	queryCacheMu   sync.Mutex
+	queryCache     = make(map[string]cacheEntry)
+	queryCacheSize = defaultQueryCacheSize
+)
+
+// SetQueryCacheSize задает максимальное количество результатов в кеше QueryStructsCached
+func SetQueryCacheSize(n int) {
+	queryCacheMu.Lock()
+	defer queryCacheMu.Unlock()
+
+	queryCacheSize = n
+	for len(queryCache) > queryCacheSize {
+		evictQueryCache(time.Now())
+	}
+}
+
+// ClearQueryCache очищает кеш QueryStructsCached
+func ClearQueryCache() {
+	queryCacheMu.Lock()
+	defer queryCacheMu.Unlock()
+
+	clear(queryCache)
+}
+
+// QueryStructsCached как QueryStructs, но хранит результат в памяти процесса ttl по ключу из db, SQL и аргументов.
+// Аргументы входят в ключ по значению: поддерживаются скаляры, строки, time.Time, слайсы и структуры из них,
+// а также указатели на такие значения (разыменовываются); указатели внутри слайсов и структур попадут в ключ адресом.
+// Возвращается копия слайса, так что изменение результата не портит кеш (сами элементы копируются поверхностно).
+// Подходит для редко меняющихся справочников; инвалидировать кеш можно через ClearQueryCache
+func QueryStructsCached[T any](ctx context.Context, db Querier, ttl time.Duration, sql string, args ...any) ([]T, error) {
+	key := queryCacheKey[T](db, sql, args)
+
+	queryCacheMu.Lock()
+	entry, ok := queryCache[key]
+	queryCacheMu.Unlock()
+
+	if ok && time.Now().Before(entry.expires) {
+		logTiming(ctx, nil, "Served %s from cache", sql)
+		return slices.Clone(entry.value.([]T)), nil
+	}
+
+	rows, err := QueryStructs[T](ctx, db, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCacheMu.Lock()
+	defer queryCacheMu.Unlock()
+
+	now := time.Now()
+	if _, exists := queryCache[key]; !exists {
+		for len(queryCache) >= queryCacheSize && len(queryCache) > 0 {
+			evictQueryCache(now)
+		}
+	}
+	if queryCacheSize > 0 {
+		queryCache[key] = cacheEntry{value: slices.Clone(rows), expires: now.Add(ttl)}
+	}
+
+	return rows, nil
+}
+
+// queryCacheKey строит ключ кеша из типа результата, источника запроса, SQL и значений аргументов
+func queryCacheKey[T any](db Querier, sql string, args []any) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\x00%s\x00%s", reflect.TypeFor[T](), querierIdentity(db), sql)
+	for _, arg := range args {
+		fmt.Fprintf(&b, "\x00%#v", derefArg(arg))
+	}
+	return b.String()
+}
+
+// querierIdentity отличает пулы, транзакции и соединения друг от друга; *DB совпадает со своим пулом
+func querierIdentity(db Querier) string {
+	if d, ok := db.(*DB); ok {
+		db = d.Pool()
+	}
+
+	v := reflect.ValueOf(db)
+	if v.Kind() == reflect.Pointer {
+		return fmt.Sprintf("%T@%x", db, v.Pointer())
+	}
+	return fmt.Sprintf("%T:%#v", db, db)
+}
+
+// derefArg разыменовывает указатели, чтобы в ключ попало значение, а не адрес
+func derefArg(arg any) any {
+	v := reflect.ValueOf(arg)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// evictQueryCache удаляет просроченные записи, а если таких нет - запись, которая истекает раньше всех
+func evictQueryCache(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	removed := false
+	for key, entry := range queryCache {
+		if !now.Before(entry.expires) {
+			delete(queryCache, key)
+			removed = true
+			continue
+		}
+		if oldestKey == "" || entry.expires.Before(oldest) {
+			oldestKey, oldest = key, entry.expires
+		}
+	}
+
+	if !removed {
+		delete(queryCache, oldestKey)
+	}
+}
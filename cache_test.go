@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// country строка справочника стран
+type country struct {
+	Code string `db:"code"`
+}
+
+// useQueryCache очищает кеш QueryStructsCached и задает его размер до конца теста
+func useQueryCache(t *testing.T, size int) {
+	t.Helper()
+
+	ClearQueryCache()
+	SetQueryCacheSize(size)
+	t.Cleanup(func() {
+		ClearQueryCache()
+		SetQueryCacheSize(defaultQueryCacheSize)
+	})
+}
+
+func TestQueryStructsCachedHit(t *testing.T) {
+	useQueryCache(t, defaultQueryCacheSize)
+	ctx := context.Background()
+
+	db := &fakeQuerier{columns: []string{"code"}, rows: [][]any{{"de"}, {"fr"}}}
+	first, err := QueryStructsCached[country](ctx, db, time.Minute, "SELECT code FROM countries WHERE region = $1", "eu")
+	if err != nil {
+		t.Fatalf("QueryStructsCached() error = %v", err)
+	}
+
+	region := "eu"
+	second, err := QueryStructsCached[country](ctx, db, time.Minute, "SELECT code FROM countries WHERE region = $1", &region)
+	if err != nil {
+		t.Fatalf("QueryStructsCached() error = %v", err)
+	}
+
+	if len(db.calls) != 1 {
+		t.Fatalf("database queried %d times, want 1", len(db.calls))
+	}
+	if want := []country{{"de"}, {"fr"}}; !reflect.DeepEqual(first, want) || !reflect.DeepEqual(second, want) {
+		t.Fatalf("QueryStructsCached() = %v and %v, want %v", first, second, want)
+	}
+
+	// изменение результата не портит кеш
+	second[0].Code = "xx"
+	third, _ := QueryStructsCached[country](ctx, db, time.Minute, "SELECT code FROM countries WHERE region = $1", "eu")
+	if third[0].Code != "de" {
+		t.Fatalf("cached result changed to %v", third)
+	}
+}
+
+func TestQueryStructsCachedMiss(t *testing.T) {
+	useQueryCache(t, defaultQueryCacheSize)
+	ctx := context.Background()
+	const query = "SELECT code FROM countries WHERE region = $1"
+
+	db := &fakeQuerier{columns: []string{"code"}, rows: [][]any{{"de"}}}
+	other := &fakeQuerier{columns: []string{"code"}, rows: [][]any{{"de"}}}
+
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, query, "eu")
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, query, "asia")
+	_, _ = QueryStructsCached[country](ctx, other, time.Minute, query, "eu")
+	if len(db.calls) != 2 || len(other.calls) != 1 {
+		t.Fatalf("database queried %d and %d times, want 2 and 1", len(db.calls), len(other.calls))
+	}
+
+	_, _ = QueryStructsCached[country](ctx, db, -time.Second, query, "africa")
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, query, "africa")
+	if len(db.calls) != 4 {
+		t.Fatalf("expired entry served from cache: %d queries, want 4", len(db.calls))
+	}
+
+	ClearQueryCache()
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, query, "eu")
+	if len(db.calls) != 5 {
+		t.Fatalf("cleared entry served from cache: %d queries, want 5", len(db.calls))
+	}
+}
+
+func TestQueryStructsCachedEviction(t *testing.T) {
+	useQueryCache(t, 2)
+	ctx := context.Background()
+
+	db := &fakeQuerier{columns: []string{"code"}, rows: [][]any{{"de"}}}
+	_, _ = QueryStructsCached[country](ctx, db, time.Second, "SELECT 1")
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, "SELECT 2")
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, "SELECT 3")
+
+	queryCacheMu.Lock()
+	size := len(queryCache)
+	queryCacheMu.Unlock()
+	if size != 2 {
+		t.Fatalf("cache holds %d entries, want 2", size)
+	}
+
+	// вытеснена запись, которая истекала раньше всех
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, "SELECT 2")
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, "SELECT 1")
+	if len(db.calls) != 4 {
+		t.Fatalf("database queried %d times, want 4", len(db.calls))
+	}
+}
+
+func TestQueryStructsCachedDisabled(t *testing.T) {
+	useQueryCache(t, 0)
+	ctx := context.Background()
+
+	db := &fakeQuerier{columns: []string{"code"}, rows: [][]any{{"de"}}}
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, "SELECT 1")
+	_, _ = QueryStructsCached[country](ctx, db, time.Minute, "SELECT 1")
+	if len(db.calls) != 2 {
+		t.Fatalf("database queried %d times with a disabled cache, want 2", len(db.calls))
+	}
+}
@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"sync"
+	"time"
+)
+
+// QueryResult результат запроса, выполняемого в фоне
+type QueryResult[T any] struct {
+	Rows []T
+	Err  error
+}
+
+// QueryCancelable запускает QueryStructs в фоне на выделенном соединении и возвращает канал с результатом
+// и функцию отмены. Отмена отправляет серверу CancelRequest, поэтому запрос прерывается и в самой базе;
+// в канал при этом придет ошибка отмены. Вызывать отмену после получения результата безопасно.
+// Отмена ctx тоже прерывает запрос
+func QueryCancelable[T any](ctx context.Context, pool *pgxpool.Pool, sql string, args ...any) (<-chan QueryResult[T], func(), error) {
+	conn, err := pool.Acquire(markAcquireStart(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	ctx, cancelCtx := context.WithCancel(ctx)
+
+	var mu sync.Mutex
+	done := false
+	result := make(chan QueryResult[T], 1)
+
+	go func() {
+		rows, err := QueryStructs[T](ctx, &pooledConn{Conn: conn, pool: pool}, sql, args...)
+
+		mu.Lock()
+		done = true
+		conn.Release()
+		mu.Unlock()
+		cancelCtx()
+
+		result <- QueryResult[T]{Rows: rows, Err: err}
+	}()
+
+	cancel := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return
+		}
+		// после освобождения соединения отмена могла бы прервать чужой запрос, поэтому она под той же блокировкой
+		reqCtx, stop := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer stop()
+		_ = conn.Conn().PgConn().CancelRequest(reqCtx)
+		cancelCtx()
+	}
+
+	return result, cancel, nil
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestQueryCancelableAcquireError(t *testing.T) {
+	pool := unreachablePool(t)
+
+	result, cancel, err := QueryCancelable[int](context.Background(), pool, "SELECT 1")
+	if err == nil {
+		t.Fatal("QueryCancelable() error = nil for an unreachable database")
+	}
+	if result != nil || cancel != nil {
+		t.Fatal("QueryCancelable() returned a result channel after a failed acquire")
+	}
+}
+
+func TestQueryCancelableResult(t *testing.T) {
+	pool := testPool(t)
+
+	result, cancel, err := QueryCancelable[int](context.Background(), pool, "SELECT g FROM generate_series(1, $1) g", 3)
+	if err != nil {
+		t.Fatalf("QueryCancelable() error = %v", err)
+	}
+
+	res := <-result
+	if res.Err != nil {
+		t.Fatalf("QueryCancelable() result error = %v", res.Err)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(res.Rows, want) {
+		t.Fatalf("QueryCancelable() rows = %v, want %v", res.Rows, want)
+	}
+
+	// после получения результата отмена ничего не делает
+	cancel()
+	if stat := pool.Stat(); stat.AcquiredConns() != 0 {
+		t.Fatalf("%d connections still acquired", stat.AcquiredConns())
+	}
+}
+
+func TestQueryCancelableCancel(t *testing.T) {
+	pool := testPool(t)
+
+	result, cancel, err := QueryCancelable[int](context.Background(), pool, "SELECT 1 FROM pg_sleep(30)")
+	if err != nil {
+		t.Fatalf("QueryCancelable() error = %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case res := <-result:
+		if !errors.Is(res.Err, context.Canceled) {
+			t.Fatalf("canceled query error = %v, want %v", res.Err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("query was not canceled")
+	}
+}
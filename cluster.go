@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/nevasik7/lg"
+	"sync/atomic"
+	"time"
+)
+
+// Cluster объединяет primary и реплики: чтение распределяется по репликам по кругу,
+// запись и транзакции выполняются на primary
+type Cluster struct {
+	primary  *pgxpool.Pool
+	replicas []*pgxpool.Pool
+	next     atomic.Uint64
+}
+
+// NewCluster создает кластер; без реплик все запросы идут на primary
+func NewCluster(primary *pgxpool.Pool, replicas ...*pgxpool.Pool) *Cluster {
+	return &Cluster{primary: primary, replicas: replicas}
+}
+
+// Write возвращает пул primary
+func (c *Cluster) Write() *pgxpool.Pool {
+	return c.primary
+}
+
+// Read возвращает следующую реплику по кругу или primary, если реплик нет
+func (c *Cluster) Read() *pgxpool.Pool {
+	if len(c.replicas) == 0 {
+		return c.primary
+	}
+
+	n := c.next.Add(1) - 1
+	return c.replicas[n%uint64(len(c.replicas))]
+}
+
+// ReadWithin возвращает реплику с отставанием не больше maxStaleness или primary.
+// Перебор начинается с очередной реплики по кругу, как в Read
+func (c *Cluster) ReadWithin(ctx context.Context, maxStaleness time.Duration) *pgxpool.Pool {
+	if len(c.replicas) == 0 {
+		return c.primary
+	}
+
+	start := c.next.Add(1) - 1
+	return pickReplica(ctx, c.primary, c.replicas, start, maxStaleness)
+}
+
+// Exec выполняет изменяющий запрос на primary
+func (c *Cluster) Exec(ctx context.Context, sql string, args ...any) error {
+	return Exec(ctx, c.primary, sql, args...)
+}
+
+// RequestInOneTransaction выполняет запросы в одной транзакции на primary
+func (c *Cluster) RequestInOneTransaction(ctx context.Context, queryParam map[string][]any) error {
+	return RequestInOneTransaction(ctx, c.primary, queryParam)
+}
+
+// Close закрывает пулы primary и реплик
+func (c *Cluster) Close() {
+	Close(c.primary)
+	for _, replica := range c.replicas {
+		Close(replica)
+	}
+}
+
+// ClusterQueryStructs выполняет QueryStructs на реплике кластера
+func ClusterQueryStructs[T any](ctx context.Context, c *Cluster, sql string, args ...any) ([]T, error) {
+	return QueryStructs[T](ctx, c.Read(), sql, args...)
+}
+
+// ClusterQuerySimple выполняет QuerySimple на реплике кластера
+func ClusterQuerySimple[T any](ctx context.Context, c *Cluster, sql string, args ...any) ([]T, error) {
+	return QuerySimple[T](ctx, c.Read(), sql, args...)
+}
+
+// ClusterQueryOne выполняет QueryOne на реплике кластера
+func ClusterQueryOne[T any](ctx context.Context, c *Cluster, sql string, args ...any) (T, error) {
+	return QueryOne[T](ctx, c.Read(), sql, args...)
+}
+
+// ClusterQueryOneStruct выполняет QueryOneStruct на реплике кластера
+func ClusterQueryOneStruct[T any](ctx context.Context, c *Cluster, sql string, args ...any) (T, error) {
+	return QueryOneStruct[T](ctx, c.Read(), sql, args...)
+}
+
+// replicationLagQuery запрос отставания реплики: время с последней примененной транзакции, на primary - 0
+const replicationLagQuery = `SELECT CASE WHEN pg_is_in_recovery()
+	THEN EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::float8
+	ELSE 0 END`
+
+// ReplicationLag возвращает отставание реплики от primary; для primary возвращает 0.
+// Если реплика еще не применила ни одной транзакции, возвращается ошибка
+func ReplicationLag(ctx context.Context, db Querier) (_ time.Duration, err error) {
+	ctx, finish, err := startQuery(ctx, db, "ReplicationLag", replicationLagQuery)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed replication lag check in %s", elapsed)
+	}()
+
+	var seconds *float64
+	if err = db.QueryRow(ctx, replicationLagQuery).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("failed to get replication lag: %w", err)
+	}
+
+	if seconds == nil {
+		return 0, fmt.Errorf("replication lag is unknown")
+	}
+
+	return time.Duration(*seconds * float64(time.Second)), nil
+}
+
+// replicationLag измеряет отставание реплики; тесты подменяют его, чтобы задать отставание без настоящих реплик
+var replicationLag = ReplicationLag
+
+// ReadPoolWithStaleness выбирает первую реплику, отставание которой не превышает maxStaleness, иначе возвращает primary
+func ReadPoolWithStaleness(ctx context.Context, primary *pgxpool.Pool, replicas []*pgxpool.Pool, maxStaleness time.Duration) *pgxpool.Pool {
+	return pickReplica(ctx, primary, replicas, 0, maxStaleness)
+}
+
+// pickReplica перебирает реплики по кругу, начиная с номера start, и возвращает первую с отставанием
+// не больше maxStaleness; если таких нет, возвращает primary
+func pickReplica(ctx context.Context, primary *pgxpool.Pool, replicas []*pgxpool.Pool, start uint64, maxStaleness time.Duration) *pgxpool.Pool {
+	for k := range uint64(len(replicas)) {
+		i := (start + k) % uint64(len(replicas))
+		lag, err := replicationLag(ctx, replicas[i])
+		if err != nil {
+			lg.Infof("Skipping replica %d: %v", i, err)
+			continue
+		}
+
+		if lag <= maxStaleness {
+			return replicas[i]
+		}
+	}
+
+	return primary
+}
@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
+	"testing"
+	"time"
+)
+
+// namedPool создает недоступный пул с именем базы name, по которому ошибка подключения выдает выбранный пул
+func namedPool(t *testing.T, name string) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := NewDB(context.Background(), &DBConfig{Host: "127.0.0.1", Port: "1", Db: name, SslMode: "disable"},
+		WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// routedTo проверяет, что ошибка подключения пришла от пула с именем базы name
+func routedTo(t *testing.T, err error, name string) {
+	t.Helper()
+
+	if err == nil || !strings.Contains(err.Error(), "database="+name) {
+		t.Fatalf("error = %v, want a connection error from %s", err, name)
+	}
+}
+
+func TestClusterReadRoundRobin(t *testing.T) {
+	primary := namedPool(t, "primary")
+	first, second := namedPool(t, "replica1"), namedPool(t, "replica2")
+	c := NewCluster(primary, first, second)
+
+	want := []*pgxpool.Pool{first, second, first, second}
+	for i, w := range want {
+		if got := c.Read(); got != w {
+			t.Fatalf("Read() #%d returned the wrong pool", i)
+		}
+	}
+	if c.Write() != primary {
+		t.Fatal("Write() did not return primary")
+	}
+}
+
+func TestClusterWithoutReplicasReadsPrimary(t *testing.T) {
+	primary := namedPool(t, "primary")
+	c := NewCluster(primary)
+
+	if c.Read() != primary {
+		t.Fatal("Read() without replicas did not return primary")
+	}
+}
+
+func TestClusterRouting(t *testing.T) {
+	ctx := context.Background()
+	c := NewCluster(namedPool(t, "primary"), namedPool(t, "replica"))
+
+	_, err := ClusterQuerySimple[int](ctx, c, "SELECT 1")
+	routedTo(t, err, "replica")
+
+	_, err = ClusterQueryStructs[struct{ N int }](ctx, c, "SELECT 1 AS n")
+	routedTo(t, err, "replica")
+
+	routedTo(t, c.Exec(ctx, "UPDATE users SET name = $1", "Ann"), "primary")
+	routedTo(t, c.RequestInOneTransaction(ctx, map[string][]any{"UPDATE users SET name = $1": {"Ann"}}), "primary")
+}
+
+func TestReadPoolWithStalenessSkipsUnavailableReplica(t *testing.T) {
+	primary := unreachablePool(t)
+	replica := unreachablePool(t)
+
+	got := ReadPoolWithStaleness(context.Background(), primary, []*pgxpool.Pool{replica}, time.Minute)
+	if got != primary {
+		t.Fatal("ReadPoolWithStaleness() did not fall back to primary for an unavailable replica")
+	}
+}
+
+func TestReplicationLagOnPrimary(t *testing.T) {
+	pool := testPool(t)
+
+	lag, err := ReplicationLag(context.Background(), pool)
+	if err != nil {
+		t.Fatalf("ReplicationLag() error = %v", err)
+	}
+	if lag != 0 {
+		t.Fatalf("ReplicationLag() = %s on primary, want 0", lag)
+	}
+}
+
+func TestReadPoolWithStalenessPicksFreshReplica(t *testing.T) {
+	fresh := testPool(t)
+	primary := unreachablePool(t)
+
+	got := ReadPoolWithStaleness(context.Background(), primary, []*pgxpool.Pool{fresh}, time.Second)
+	if got != fresh {
+		t.Fatal("ReadPoolWithStaleness() = primary, want the replica within the staleness bound")
+	}
+}
+
+// useReplicationLag подменяет измерение отставания реплик до конца теста
+func useReplicationLag(t *testing.T, lags map[Querier]time.Duration) {
+	t.Helper()
+
+	prev := replicationLag
+	replicationLag = func(ctx context.Context, db Querier) (time.Duration, error) {
+		return lags[db], nil
+	}
+	t.Cleanup(func() { replicationLag = prev })
+}
+
+func TestReadPoolWithStalenessFallsBackWhenAllReplicasLag(t *testing.T) {
+	primary := namedPool(t, "primary")
+	first, second := namedPool(t, "replica1"), namedPool(t, "replica2")
+	useReplicationLag(t, map[Querier]time.Duration{first: time.Minute, second: 2 * time.Second})
+
+	if got := ReadPoolWithStaleness(context.Background(), primary, []*pgxpool.Pool{first, second}, time.Second); got != primary {
+		t.Fatal("ReadPoolWithStaleness() did not fall back to primary when every replica lags")
+	}
+
+	c := NewCluster(primary, first, second)
+	if got := c.ReadWithin(context.Background(), time.Second); got != primary {
+		t.Fatal("ReadWithin() did not fall back to primary when every replica lags")
+	}
+}
+
+func TestClusterReadWithinRoundRobin(t *testing.T) {
+	primary := namedPool(t, "primary")
+	first, second, lagging := namedPool(t, "replica1"), namedPool(t, "replica2"), namedPool(t, "replica3")
+	useReplicationLag(t, map[Querier]time.Duration{lagging: time.Minute})
+	c := NewCluster(primary, first, second, lagging)
+
+	want := []*pgxpool.Pool{first, second, first, first, second}
+	for i, w := range want {
+		if got := c.ReadWithin(context.Background(), time.Second); got != w {
+			t.Fatalf("ReadWithin() #%d returned the wrong pool", i)
+		}
+	}
+}
+
+func TestReplicationLagReportsOperation(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"lag"}, rows: [][]any{{1.5}}}
+
+	var ops []string
+	remove := AddQueryObserver(func(q Querier, op string, duration time.Duration, err error) {
+		if q == Querier(db) {
+			ops = append(ops, op)
+		}
+	})
+	defer remove()
+
+	lag, err := ReplicationLag(context.Background(), db)
+	if err != nil {
+		t.Fatalf("ReplicationLag() error = %v", err)
+	}
+	if lag != 1500*time.Millisecond {
+		t.Fatalf("ReplicationLag() = %s, want 1.5s", lag)
+	}
+	if len(ops) != 1 || ops[0] != "ReplicationLag" {
+		t.Fatalf("observed operations %v, want [ReplicationLag]", ops)
+	}
+
+	unknown := &fakeQuerier{columns: []string{"lag"}, rows: [][]any{{nil}}}
+	if _, err := ReplicationLag(context.Background(), unknown); err == nil {
+		t.Fatal("ReplicationLag() error = nil for a replica without replayed transactions")
+	}
+}
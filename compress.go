@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decompressor распаковывает содержимое bytea-колонки
+type Decompressor func(data []byte) ([]byte, error)
+
+// GzipDecompressor распаковывает данные в формате gzip
+func GzipDecompressor(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if errors.Is(err, gzip.ErrHeader) || errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("payload is not gzip-compressed: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// QueryDecompressed читает одну bytea-колонку и распаковывает ее из gzip
+func QueryDecompressed(ctx context.Context, db Querier, sql string, args ...any) ([]byte, error) {
+	return QueryDecompressedWith(ctx, db, GzipDecompressor, sql, args...)
+}
+
+// QueryDecompressedWith читает одну bytea-колонку и распаковывает ее переданным decompress
+func QueryDecompressedWith(ctx context.Context, db Querier, decompress Decompressor, sql string, args ...any) ([]byte, error) {
+	data, err := QueryOne[[]byte](ctx, db, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := decompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress: %w", err)
+	}
+
+	return plain, nil
+}
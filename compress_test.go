@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to compress: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestGzipDecompressor(t *testing.T) {
+	plain, err := GzipDecompressor(gzipBytes(t, []byte("payload")))
+	if err != nil {
+		t.Fatalf("GzipDecompressor() error = %v", err)
+	}
+	if string(plain) != "payload" {
+		t.Fatalf("GzipDecompressor() = %q, want payload", plain)
+	}
+}
+
+func TestGzipDecompressorRejectsPlainData(t *testing.T) {
+	for _, data := range [][]byte{[]byte("not gzip at all"), nil} {
+		if _, err := GzipDecompressor(data); err == nil {
+			t.Errorf("GzipDecompressor(%q) error = nil, want error", data)
+		}
+	}
+}
+
+func TestQueryDecompressed(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"body"}, rows: [][]any{{gzipBytes(t, []byte("payload"))}}}
+
+	plain, err := QueryDecompressed(context.Background(), db, "SELECT body FROM documents WHERE id = $1", 1)
+	if err != nil {
+		t.Fatalf("QueryDecompressed() error = %v", err)
+	}
+	if string(plain) != "payload" {
+		t.Fatalf("QueryDecompressed() = %q, want payload", plain)
+	}
+}
+
+func TestQueryDecompressedWith(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"body"}, rows: [][]any{{[]byte("abc")}}}
+	broken := errors.New("broken")
+
+	upper := func(data []byte) ([]byte, error) { return bytes.ToUpper(data), nil }
+	plain, err := QueryDecompressedWith(context.Background(), db, upper, "SELECT body FROM documents")
+	if err != nil || string(plain) != "ABC" {
+		t.Fatalf("QueryDecompressedWith() = %q, %v, want ABC, nil", plain, err)
+	}
+
+	failing := func(data []byte) ([]byte, error) { return nil, broken }
+	if _, err = QueryDecompressedWith(context.Background(), db, failing, "SELECT body FROM documents"); !errors.Is(err, broken) {
+		t.Fatalf("QueryDecompressedWith() error = %v, want %v", err, broken)
+	}
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+// WithConn выделяет из пула отдельное соединение, выполняет на нем fn и возвращает соединение в пул (в том числе при панике).
+// Нужен для операций, привязанных к сессии: SET, advisory locks, LISTEN
+func WithConn(ctx context.Context, pool *pgxpool.Pool, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := pool.Acquire(markAcquireStart(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	return fn(conn)
+}
+
+// AcquireWithTimeout получает соединение из пула, ожидая не дольше timeout, и возвращает ErrAcquireTimeout,
+// если пул исчерпан. Ограничивается только ожидание соединения, а не работа с ним; соединение нужно вернуть через Release
+func AcquireWithTimeout(ctx context.Context, pool *pgxpool.Pool, timeout time.Duration) (*pgxpool.Conn, error) {
+	acquireCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := pool.Acquire(markAcquireStart(acquireCtx))
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, fmt.Errorf("%w after %s", ErrAcquireTimeout, timeout)
+		}
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	return conn, nil
+}
+
+// WithSearchPath выполняет fn на выделенном соединении с search_path, установленным в schema,
+// и сбрасывает search_path перед возвратом соединения в пул. Если сбросить не удалось, соединение закрывается
+func WithSearchPath(ctx context.Context, pool *pgxpool.Pool, schema string, fn func(db Querier) error) error {
+	ident, err := sanitizeIdentifier(schema)
+	if err != nil {
+		return err
+	}
+
+	return WithConn(ctx, pool, func(conn *pgxpool.Conn) (err error) {
+		if _, err = conn.Exec(ctx, "SET search_path TO "+ident); err != nil {
+			return fmt.Errorf("failed to set search_path: %w", err)
+		}
+		defer func() {
+			resetCtx := context.WithoutCancel(ctx)
+			if _, resetErr := conn.Exec(resetCtx, "RESET search_path"); resetErr != nil {
+				// закрытие сессии не дает соединению с чужим search_path вернуться в пул
+				_ = conn.Conn().Close(resetCtx)
+				if err == nil {
+					err = fmt.Errorf("failed to reset search_path: %w", resetErr)
+				}
+			}
+		}()
+
+		return fn(&pooledConn{Conn: conn, pool: pool})
+	})
+}
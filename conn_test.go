@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unreachablePool создает пул к адресу, на котором никто не слушает
+func unreachablePool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := NewDB(context.Background(), &DBConfig{Host: "127.0.0.1", Port: "1", Db: "test", SslMode: "disable"},
+		WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func TestWithConnAcquireError(t *testing.T) {
+	pool := unreachablePool(t)
+
+	called := false
+	err := WithConn(context.Background(), pool, func(conn *pgxpool.Conn) error {
+		called = true
+		return nil
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "failed to acquire connection") {
+		t.Fatalf("WithConn() error = %v, want acquire error", err)
+	}
+	if called {
+		t.Fatal("fn was called without a connection")
+	}
+}
+
+func TestWithConnKeepsSession(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	err := WithConn(ctx, pool, func(conn *pgxpool.Conn) error {
+		mustExec(t, conn, "SET application_name = 'with_conn_test'")
+
+		var name string
+		if err := conn.QueryRow(ctx, "SHOW application_name").Scan(&name); err != nil {
+			return err
+		}
+		if name != "with_conn_test" {
+			t.Errorf("application_name = %q, want with_conn_test", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithConn() error = %v", err)
+	}
+
+	if acquired := pool.Stat().AcquiredConns(); acquired != 0 {
+		t.Fatalf("AcquiredConns() = %d after WithConn, want 0", acquired)
+	}
+}
+
+func TestWithConnReleasesOnPanic(t *testing.T) {
+	pool := testPool(t)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was not propagated")
+			}
+		}()
+		_ = WithConn(context.Background(), pool, func(conn *pgxpool.Conn) error {
+			panic("boom")
+		})
+	}()
+
+	if acquired := pool.Stat().AcquiredConns(); acquired != 0 {
+		t.Fatalf("AcquiredConns() = %d after panic, want 0", acquired)
+	}
+}
+
+func TestAcquireWithTimeoutOnSaturatedPool(t *testing.T) {
+	pool := testPool(t, WithMaxConns(1))
+	ctx := context.Background()
+
+	held, err := AcquireWithTimeout(ctx, pool, time.Second)
+	if err != nil {
+		t.Fatalf("first AcquireWithTimeout() error = %v", err)
+	}
+	defer held.Release()
+
+	start := time.Now()
+	_, err = AcquireWithTimeout(ctx, pool, 100*time.Millisecond)
+	if !errors.Is(err, ErrAcquireTimeout) {
+		t.Fatalf("second AcquireWithTimeout() error = %v, want %v", err, ErrAcquireTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("second AcquireWithTimeout() waited %s, want about 100ms", elapsed)
+	}
+}
+
+func TestAcquireWithTimeoutCallerCanceled(t *testing.T) {
+	pool := testPool(t, WithMaxConns(1))
+
+	held, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer held.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = AcquireWithTimeout(ctx, pool, time.Minute)
+	if errors.Is(err, ErrAcquireTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AcquireWithTimeout() error = %v, want the caller's deadline", err)
+	}
+}
+
+func TestWithSearchPathRejectsInvalidSchema(t *testing.T) {
+	called := false
+	err := WithSearchPath(context.Background(), unreachablePool(t), `tenant"; DROP SCHEMA public;--`, func(db Querier) error {
+		called = true
+		return nil
+	})
+
+	if !errors.Is(err, ErrInvalidIdentifier) {
+		t.Fatalf("WithSearchPath() error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+	if called {
+		t.Fatal("fn was called for an invalid schema")
+	}
+}
+
+func TestWithSearchPath(t *testing.T) {
+	pool := testPool(t, WithMaxConns(1))
+	ctx := context.Background()
+
+	suffix := time.Now().UnixNano()
+	for _, tenant := range []string{"a", "b"} {
+		schema := fmt.Sprintf("test_tenant_%s_%d", tenant, suffix)
+		mustExec(t, pool, "CREATE SCHEMA "+schema)
+		t.Cleanup(func() {
+			_, _ = pool.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+		})
+		mustExec(t, pool, fmt.Sprintf("CREATE TABLE %s.accounts (tenant text); INSERT INTO %s.accounts VALUES ('%s')", schema, schema, tenant))
+	}
+
+	for _, tenant := range []string{"a", "b"} {
+		schema := fmt.Sprintf("test_tenant_%s_%d", tenant, suffix)
+
+		err := WithSearchPath(ctx, pool, schema, func(db Querier) error {
+			got, err := QueryOne[string](ctx, db, "SELECT tenant FROM accounts")
+			if err != nil {
+				return err
+			}
+			if got != tenant {
+				t.Errorf("search_path %s read tenant %q", schema, got)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("WithSearchPath(%s) error = %v", schema, err)
+		}
+	}
+
+	path, err := QueryOne[string](ctx, pool, "SHOW search_path")
+	if err != nil {
+		t.Fatalf("SHOW search_path error = %v", err)
+	}
+	if strings.Contains(path, "test_tenant_") {
+		t.Fatalf("search_path %q leaked back into the pool", path)
+	}
+}
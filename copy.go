@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io"
+	"strings"
+	"time"
+)
+
+// CopyProgressFunc вызывается с количеством уже переданных строк
+type CopyProgressFunc func(copied int64)
+
+// CopyIn выполняет вставку строк через протокол COPY и возвращает количество вставленных строк.
+// Если progress задан, он вызывается каждые every строк
+func CopyIn(ctx context.Context, pool *pgxpool.Pool, tableName string, columns []string, rows [][]any, every int, progress CopyProgressFunc) (_ int64, err error) {
+	ctx, finish, err := startQuery(ctx, pool, "CopyIn", "COPY "+tableName)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed copy to the %s in %s", tableName, elapsed)
+	}()
+
+	if _, err = sanitizeIdentifiers(append([]string{tableName}, columns...)); err != nil {
+		return 0, err
+	}
+
+	var src pgx.CopyFromSource = pgx.CopyFromRows(rows)
+	if progress != nil && every > 0 {
+		src = &progressSource{CopyFromSource: src, every: int64(every), progress: progress}
+	}
+
+	copied, err := pool.CopyFrom(ctx, pgx.Identifier(strings.Split(tableName, ".")), columns, src)
+	if err != nil {
+		return copied, fmt.Errorf("copy failed: %w", err)
+	}
+
+	return copied, nil
+}
+
+// CopyOut выполняет COPY ... TO STDOUT и пишет вывод сервера в w, возвращая количество выгруженных строк.
+// sql - целая команда, например "COPY (SELECT id, name FROM users) TO STDOUT WITH (FORMAT csv, HEADER)".
+// COPY не принимает параметры ($1), поэтому значения в запрос нужно подставлять самостоятельно и безопасно
+func CopyOut(ctx context.Context, pool *pgxpool.Pool, w io.Writer, sql string) (_ int64, err error) {
+	ctx, finish, err := startQuery(ctx, pool, "CopyOut", sql)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed %s in %s", sql, elapsed)
+	}()
+
+	conn, err := pool.Acquire(markAcquireStart(ctx))
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	tag, err := conn.Conn().PgConn().CopyTo(ctx, w, sql)
+	if err != nil {
+		return 0, fmt.Errorf("copy failed: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"reflect"
+	"testing"
+)
+
+func TestProgressSourceReportsEveryN(t *testing.T) {
+	var reported []int64
+	src := &progressSource{
+		CopyFromSource: pgx.CopyFromRows([][]any{{1}, {2}, {3}, {4}, {5}}),
+		every:          2,
+		progress:       func(copied int64) { reported = append(reported, copied) },
+	}
+
+	n := 0
+	for src.Next() {
+		n++
+	}
+
+	if n != 5 {
+		t.Fatalf("Next() returned true %d times, want 5", n)
+	}
+	if want := []int64{2, 4}; !reflect.DeepEqual(reported, want) {
+		t.Fatalf("progress reported %v, want %v", reported, want)
+	}
+}
+
+func TestCopyInRejectsInvalidIdentifier(t *testing.T) {
+	_, err := CopyIn(context.Background(), nil, `users"; DROP TABLE users; --`, []string{"id"}, nil, 0, nil)
+	if !errors.Is(err, ErrInvalidIdentifier) {
+		t.Fatalf("CopyIn() error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+}
+
+func TestCopyInWithProgress(t *testing.T) {
+	pool := testPool(t)
+	table := testTable(t, pool, "id int, name text")
+
+	rows := make([][]any, 10)
+	for i := range rows {
+		rows[i] = []any{i, "name"}
+	}
+
+	var reported []int64
+	copied, err := CopyIn(context.Background(), pool, table, []string{"id", "name"}, rows, 4, func(n int64) {
+		reported = append(reported, n)
+	})
+	if err != nil {
+		t.Fatalf("CopyIn() error = %v", err)
+	}
+
+	if copied != 10 {
+		t.Fatalf("CopyIn() = %d, want 10", copied)
+	}
+	if want := []int64{4, 8}; !reflect.DeepEqual(reported, want) {
+		t.Fatalf("progress reported %v, want %v", reported, want)
+	}
+
+	count, err := Count(context.Background(), pool, "SELECT count(*) FROM "+table)
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if count != 10 {
+		t.Fatalf("table has %d rows, want 10", count)
+	}
+}
+
+func TestCopyOut(t *testing.T) {
+	pool := testPool(t)
+	table := testTable(t, pool, "id int, name text")
+	mustExec(t, pool, "INSERT INTO "+table+" VALUES (1, 'Ann'), (2, 'Smith, John'), (3, NULL)")
+
+	var buf bytes.Buffer
+	copied, err := CopyOut(context.Background(), pool, &buf,
+		"COPY (SELECT id, name FROM "+table+" ORDER BY id) TO STDOUT WITH (FORMAT csv, HEADER)")
+	if err != nil {
+		t.Fatalf("CopyOut() error = %v", err)
+	}
+
+	if copied != 3 {
+		t.Fatalf("CopyOut() = %d, want 3", copied)
+	}
+	want := "id,name\n1,Ann\n2,\"Smith, John\"\n3,\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("CopyOut() wrote %q, want %q", got, want)
+	}
+}
+
+func TestCopyOutInvalidCommand(t *testing.T) {
+	pool := testPool(t)
+
+	var buf bytes.Buffer
+	if _, err := CopyOut(context.Background(), pool, &buf, "COPY no_such_table_xyz TO STDOUT"); err == nil {
+		t.Fatal("CopyOut() error = nil, want an error for a missing table")
+	}
+}
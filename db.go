@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/nevasik7/lg"
+	"sync"
+)
+
+// DB хранит конфигурацию вместе с пулом и умеет пересоздать пул после потери базы (рестарт, failover).
+// Реализует Querier, поэтому передается в хелперы вместо *pgxpool.Pool
+type DB struct {
+	cfg  DBConfig
+	opts []Option
+
+	mu   sync.RWMutex
+	pool *pgxpool.Pool
+}
+
+// OpenDB создает пул по конфигурации и сохраняет ее для пересоздания пула в EnsureHealthy
+func OpenDB(ctx context.Context, cfg *DBConfig, opts ...Option) (*DB, error) {
+	pool, err := NewDB(ctx, cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{cfg: *cfg, opts: opts, pool: pool}, nil
+}
+
+// Pool возвращает текущий пул; после EnsureHealthy он может смениться
+func (d *DB) Pool() *pgxpool.Pool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.pool
+}
+
+// EnsureHealthy проверяет пул через Ping и, если база недоступна через него (пул закрыт,
+// соединения разорваны при рестарте), создает новый пул из сохраненной конфигурации, а старый закрывает
+func (d *DB) EnsureHealthy(ctx context.Context) error {
+	pool := d.Pool()
+	err := pool.Ping(ctx)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// пул мог пересоздать конкурентный вызов, пока ждали блокировку
+	if d.pool != pool {
+		return d.pool.Ping(ctx)
+	}
+
+	lg.Warnf("Recreating connection pool after failed ping: %v", err)
+	fresh, err := NewDB(ctx, &d.cfg, d.opts...)
+	if err != nil {
+		return fmt.Errorf("failed to recreate pool: %w", err)
+	}
+	if err = fresh.Ping(ctx); err != nil {
+		fresh.Close()
+		return fmt.Errorf("recreated pool is not healthy: %w", err)
+	}
+
+	d.pool = fresh
+	// Close ждет возврата выданных соединений, поэтому не блокирует вызывающего
+	go pool.Close()
+
+	return nil
+}
+
+// Close закрывает текущий пул
+func (d *DB) Close() {
+	d.Pool().Close()
+}
+
+func (d *DB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return d.Pool().Query(ctx, sql, args...)
+}
+
+func (d *DB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return d.Pool().QueryRow(ctx, sql, args...)
+}
+
+func (d *DB) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return d.Pool().Exec(ctx, sql, arguments...)
+}
+
+func (d *DB) Begin(ctx context.Context) (pgx.Tx, error) {
+	return d.Pool().Begin(ctx)
+}
+
+func (d *DB) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	return d.Pool().SendBatch(ctx, b)
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testDBConfig строит DBConfig по POSTGRES_TEST_URL или пропускает тест
+func testDBConfig(t *testing.T) *DBConfig {
+	t.Helper()
+
+	url := os.Getenv(testURLEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testURLEnv)
+	}
+
+	parsed, err := pgx.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", testURLEnv, err)
+	}
+
+	sslMode := "disable"
+	if parsed.TLSConfig != nil {
+		sslMode = "require"
+	}
+
+	return &DBConfig{
+		Host:     parsed.Host,
+		Port:     strconv.Itoa(int(parsed.Port)),
+		User:     parsed.User,
+		Password: parsed.Password,
+		Db:       parsed.Database,
+		SslMode:  sslMode,
+	}
+}
+
+func TestOpenDBValidatesConfig(t *testing.T) {
+	if _, err := OpenDB(context.Background(), &DBConfig{Db: "test"}); err == nil {
+		t.Fatal("OpenDB() error = nil without Host")
+	}
+}
+
+func TestEnsureHealthyKeepsPoolWhenDatabaseIsDown(t *testing.T) {
+	db, err := OpenDB(context.Background(), &DBConfig{Host: "127.0.0.1", Port: "1", Db: "test", SslMode: "disable"},
+		WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("OpenDB() error = %v", err)
+	}
+	defer db.Close()
+
+	pool := db.Pool()
+	if err = db.EnsureHealthy(context.Background()); err == nil {
+		t.Fatal("EnsureHealthy() error = nil for an unreachable database")
+	}
+	if db.Pool() != pool {
+		t.Fatal("EnsureHealthy() replaced the pool with an unhealthy one")
+	}
+}
+
+func TestEnsureHealthyRecreatesClosedPool(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := OpenDB(ctx, testDBConfig(t))
+	if err != nil {
+		t.Fatalf("OpenDB() error = %v", err)
+	}
+	defer db.Close()
+
+	if err = db.EnsureHealthy(ctx); err != nil {
+		t.Fatalf("EnsureHealthy() on a healthy pool error = %v", err)
+	}
+
+	closed := db.Pool()
+	closed.Close()
+	if _, err = QueryOne[int](ctx, db, "SELECT 1"); err == nil {
+		t.Fatal("QueryOne() on a closed pool error = nil")
+	}
+
+	if err = db.EnsureHealthy(ctx); err != nil {
+		t.Fatalf("EnsureHealthy() error = %v", err)
+	}
+	if db.Pool() == closed {
+		t.Fatal("EnsureHealthy() kept the closed pool")
+	}
+
+	if n, err := QueryOne[int](ctx, db, "SELECT 1"); err != nil || n != 1 {
+		t.Fatalf("QueryOne() after EnsureHealthy = %d, %v, want 1", n, err)
+	}
+}
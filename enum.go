@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// registeredEnum enum-тип для регистрации на новых соединениях
+type registeredEnum struct {
+	name   string
+	values []string
+}
+
+// codeUndefinedObject SQLSTATE ошибки "type ... does not exist"
+const codeUndefinedObject = "42704"
+
+var (
+	enumsMu sync.RWMutex
+	enums   []registeredEnum
+)
+
+// RegisterEnum регистрирует enum-тип PostgreSQL и его массив на каждом новом соединении пулов библиотеки,
+// чтобы колонки этого типа сканировались в строковые типы Go (type Status string) и []Status.
+// Уже открытые соединения не затрагиваются, поэтому вызывайте до NewDB. Если values заданы,
+// при расхождении с метками типа в базе пишется предупреждение. Если типа нет в базе соединения
+// (например, у пула другого кластера), он пропускается с предупреждением, а соединение открывается как обычно
+func RegisterEnum(name string, values ...string) {
+	enumsMu.Lock()
+	defer enumsMu.Unlock()
+
+	enums = append(enums, registeredEnum{name: name, values: values})
+}
+
+// registerEnums загружает зарегистрированные enum-типы на соединение
+func registerEnums(ctx context.Context, conn *pgx.Conn) error {
+	enumsMu.RLock()
+	registered := slices.Clone(enums)
+	enumsMu.RUnlock()
+
+next:
+	for _, e := range registered {
+		arrayName := "_" + e.name
+		if schema, typ, ok := strings.Cut(e.name, "."); ok {
+			arrayName = schema + "._" + typ
+		}
+
+		for _, name := range []string{e.name, arrayName} {
+			t, err := conn.LoadType(ctx, name)
+			if hasCode(err, codeUndefinedObject) {
+				logWarnf("Enum %s does not exist in database %s, skipping registration", name, conn.Config().Database)
+				continue next
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load enum %s: %w", name, err)
+			}
+			conn.TypeMap().RegisterType(t)
+		}
+
+		if len(e.values) == 0 {
+			continue
+		}
+
+		var labels []string
+		if err := conn.QueryRow(ctx, "SELECT enum_range(NULL::"+pgx.Identifier(strings.Split(e.name, ".")).Sanitize()+")::text[]").Scan(&labels); err != nil {
+			return fmt.Errorf("failed to read enum %s labels: %w", e.name, err)
+		}
+		if !slices.Equal(labels, e.values) {
+			logWarnf("Enum %s has labels %v in database, registered with %v", e.name, labels, e.values)
+		}
+	}
+
+	return nil
+}
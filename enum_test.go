@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testStatus string
+
+// testEnum создает enum-тип с уникальным именем, регистрирует его через RegisterEnum и убирает после теста
+func testEnum(t *testing.T, values ...string) string {
+	t.Helper()
+
+	admin := testPool(t)
+	name := fmt.Sprintf("test_status_%d", time.Now().UnixNano())
+	mustExec(t, admin, "CREATE TYPE "+name+" AS ENUM ('new', 'paid', 'shipped')")
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP TYPE IF EXISTS "+name+" CASCADE")
+	})
+
+	enumsMu.Lock()
+	prev := enums
+	enumsMu.Unlock()
+	t.Cleanup(func() {
+		enumsMu.Lock()
+		enums = prev
+		enumsMu.Unlock()
+	})
+
+	RegisterEnum(name, values...)
+	return name
+}
+
+func TestRegisterEnumRoundTrip(t *testing.T) {
+	enum := testEnum(t, "new", "paid", "shipped")
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, fmt.Sprintf("id int, status %s, history %s[]", enum, enum))
+
+	type order struct {
+		ID      int          `db:"id"`
+		Status  testStatus   `db:"status"`
+		History []testStatus `db:"history"`
+	}
+
+	want := order{ID: 1, Status: "paid", History: []testStatus{"new", "paid"}}
+	mustExec(t, pool, "INSERT INTO "+table+" VALUES ($1, $2, $3)", want.ID, want.Status, want.History)
+
+	got, err := QueryOneStruct[order](ctx, pool, "SELECT id, status, history FROM "+table)
+	if err != nil {
+		t.Fatalf("QueryOneStruct() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryOneStruct() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegisterEnumWarnsOnLabelMismatch(t *testing.T) {
+	enum := testEnum(t, "new", "paid")
+	logs := captureLogs(t)
+	pool := testPool(t)
+
+	if _, err := QueryOne[int](context.Background(), pool, "SELECT 1"); err != nil {
+		t.Fatalf("QueryOne() error = %v", err)
+	}
+
+	if _, warns, _ := logs.snapshot(); len(warns) != 1 {
+		t.Fatalf("warnings = %q for enum %s with a missing label, want one", warns, enum)
+	}
+}
+
+func TestRegisterEnumSkipsMissingType(t *testing.T) {
+	enumsMu.Lock()
+	prev := enums
+	enumsMu.Unlock()
+	t.Cleanup(func() {
+		enumsMu.Lock()
+		enums = prev
+		enumsMu.Unlock()
+	})
+
+	RegisterEnum(fmt.Sprintf("missing_status_%d", time.Now().UnixNano()), "new")
+	logs := captureLogs(t)
+	pool := testPool(t)
+
+	if _, err := QueryOne[int](context.Background(), pool, "SELECT 1"); err != nil {
+		t.Fatalf("QueryOne() error = %v, want the connection to open without the missing enum", err)
+	}
+	if _, warns, _ := logs.snapshot(); len(warns) != 1 {
+		t.Fatalf("warnings = %q for a missing enum, want one", warns)
+	}
+}
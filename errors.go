@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды SQLSTATE нарушений ограничений
+const (
+	CodeUniqueViolation     = "23505"
+	CodeForeignKeyViolation = "23503"
+	CodeNotNullViolation    = "23502"
+	CodeCheckViolation      = "23514"
+)
+
+// Коды SQLSTATE конфликтов конкурентных транзакций
+const (
+	CodeSerializationFailure = "40001"
+	CodeDeadlockDetected     = "40P01"
+)
+
+// PgError сведения об ошибке, которую вернул сервер PostgreSQL
+type PgError struct {
+	Code           string
+	Message        string
+	Detail         string
+	Hint           string
+	SchemaName     string
+	TableName      string
+	ColumnName     string
+	ConstraintName string
+}
+
+func (e *PgError) Error() string {
+	return e.Message + " (SQLSTATE " + e.Code + ")"
+}
+
+// AsPgError извлекает из цепочки ошибок ошибку сервера PostgreSQL
+func AsPgError(err error) (*PgError, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil, false
+	}
+
+	return &PgError{
+		Code:           pgErr.Code,
+		Message:        pgErr.Message,
+		Detail:         pgErr.Detail,
+		Hint:           pgErr.Hint,
+		SchemaName:     pgErr.SchemaName,
+		TableName:      pgErr.TableName,
+		ColumnName:     pgErr.ColumnName,
+		ConstraintName: pgErr.ConstraintName,
+	}, true
+}
+
+// IsUniqueViolation сообщает о нарушении уникальности
+func IsUniqueViolation(err error) bool {
+	return hasCode(err, CodeUniqueViolation)
+}
+
+// IsForeignKeyViolation сообщает о нарушении внешнего ключа
+func IsForeignKeyViolation(err error) bool {
+	return hasCode(err, CodeForeignKeyViolation)
+}
+
+// IsNotNullViolation сообщает о попытке записать NULL в колонку NOT NULL
+func IsNotNullViolation(err error) bool {
+	return hasCode(err, CodeNotNullViolation)
+}
+
+// IsCheckViolation сообщает о нарушении ограничения CHECK
+func IsCheckViolation(err error) bool {
+	return hasCode(err, CodeCheckViolation)
+}
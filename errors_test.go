@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"testing"
+)
+
+func TestAsPgError(t *testing.T) {
+	wrapped := fmt.Errorf("failed to insert: %w", &pgconn.PgError{
+		Code:           CodeUniqueViolation,
+		Message:        "duplicate key value violates unique constraint",
+		Detail:         "Key (email)=(a@example.com) already exists.",
+		TableName:      "users",
+		ConstraintName: "users_email_key",
+	})
+
+	pgErr, ok := AsPgError(wrapped)
+	if !ok {
+		t.Fatal("AsPgError() ok = false")
+	}
+	if pgErr.Code != CodeUniqueViolation || pgErr.TableName != "users" || pgErr.ConstraintName != "users_email_key" {
+		t.Fatalf("AsPgError() = %+v", pgErr)
+	}
+	if pgErr.Detail == "" {
+		t.Fatal("AsPgError() lost Detail")
+	}
+
+	if _, ok = AsPgError(errors.New("plain error")); ok {
+		t.Fatal("AsPgError() ok = true for a non-server error")
+	}
+	if _, ok = AsPgError(nil); ok {
+		t.Fatal("AsPgError() ok = true for nil")
+	}
+}
+
+func TestViolationPredicates(t *testing.T) {
+	predicates := map[string]func(error) bool{
+		CodeUniqueViolation:     IsUniqueViolation,
+		CodeForeignKeyViolation: IsForeignKeyViolation,
+		CodeNotNullViolation:    IsNotNullViolation,
+		CodeCheckViolation:      IsCheckViolation,
+	}
+
+	for code, is := range predicates {
+		for other := range predicates {
+			err := fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: other})
+			if got := is(err); got != (code == other) {
+				t.Errorf("predicate for %s on %s = %v", code, other, got)
+			}
+		}
+		if is(nil) {
+			t.Errorf("predicate for %s on nil = true", code)
+		}
+	}
+}
+
+func TestViolationsFromServer(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	parent := testTable(t, pool, "id int PRIMARY KEY")
+	child := testTable(t, pool, fmt.Sprintf(
+		"id int PRIMARY KEY, parent_id int REFERENCES %s (id), name text NOT NULL, qty int CHECK (qty > 0)", parent))
+	mustExec(t, pool, "INSERT INTO "+parent+" (id) VALUES (1)")
+	mustExec(t, pool, "INSERT INTO "+child+" (id, parent_id, name, qty) VALUES (1, 1, 'a', 1)")
+
+	tests := []struct {
+		name string
+		sql  string
+		is   func(error) bool
+		code string
+	}{
+		{"unique", "INSERT INTO " + child + " (id, parent_id, name, qty) VALUES (1, 1, 'b', 1)", IsUniqueViolation, CodeUniqueViolation},
+		{"foreign key", "INSERT INTO " + child + " (id, parent_id, name, qty) VALUES (2, 42, 'b', 1)", IsForeignKeyViolation, CodeForeignKeyViolation},
+		{"not null", "INSERT INTO " + child + " (id, parent_id, name, qty) VALUES (3, 1, NULL, 1)", IsNotNullViolation, CodeNotNullViolation},
+		{"check", "INSERT INTO " + child + " (id, parent_id, name, qty) VALUES (4, 1, 'b', 0)", IsCheckViolation, CodeCheckViolation},
+	}
+
+	for _, tt := range tests {
+		_, err := pool.Exec(ctx, tt.sql)
+		if !tt.is(err) {
+			t.Errorf("%s: predicate = false for %v", tt.name, err)
+			continue
+		}
+
+		pgErr, ok := AsPgError(err)
+		if !ok || pgErr.Code != tt.code || pgErr.TableName != child {
+			t.Errorf("%s: AsPgError() = %+v, %v", tt.name, pgErr, ok)
+		}
+	}
+}
+
+func TestCanceledError(t *testing.T) {
+	errServer := &pgconn.PgError{Code: "57014", Message: "canceling statement due to user request"}
+
+	err := canceledError("QueryStructs", context.Canceled, errServer)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceledError() = %v, want it to match %v", err, context.Canceled)
+	}
+	if pgErr, ok := AsPgError(err); !ok || pgErr.Code != "57014" {
+		t.Fatalf("canceledError() = %v, want it to keep the server error", err)
+	}
+
+	wrapped := fmt.Errorf("read failed: %w", context.DeadlineExceeded)
+	err = canceledError("Exec", context.DeadlineExceeded, wrapped)
+	if want := "Exec canceled: read failed: context deadline exceeded"; err.Error() != want {
+		t.Fatalf("canceledError() = %q, want %q", err, want)
+	}
+}
+
+func TestCanceledQueryIsWarning(t *testing.T) {
+	logs := captureLogs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	db := &fakeQuerier{err: errors.New("conn closed")}
+	_, err := QueryStructs[int](ctx, db, "SELECT 1")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("QueryStructs() error = %v, want %v", err, context.Canceled)
+	}
+
+	_, warns, errs := logs.snapshot()
+	if len(errs) != 0 {
+		t.Fatalf("canceled query logged errors %q", errs)
+	}
+	if len(warns) != 1 {
+		t.Fatalf("canceled query logged warnings %q, want one", warns)
+	}
+}
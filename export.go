@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+)
+
+// QueryCSV выполняет запрос и построчно пишет результат в w в формате CSV: первая строка - имена колонок.
+// NULL записывается пустым полем, значения с запятыми и кавычками экранируются по RFC 4180
+func QueryCSV(ctx context.Context, db Querier, w io.Writer, sql string, args ...any) (err error) {
+	ctx, finish, err := startQuery(ctx, db, "QueryCSV", sql)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed csv export of %s in %s", sql, elapsed)
+	}()
+
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	record := make([]string, len(fields))
+	for i, f := range fields {
+		record[i] = f.Name
+	}
+
+	cw := csv.NewWriter(w)
+	if err = cw.Write(record); err != nil {
+		return fmt.Errorf("failed to write csv header: %w", err)
+	}
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+
+		for i, v := range values {
+			record[i] = csvValue(v)
+		}
+		if err = cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write csv row: %w", err)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+
+	return nil
+}
+
+// csvValue переводит значение колонки в поле CSV
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case driver.Valuer:
+		// pgtype (Numeric, Interval и другие) отдают текстовое представление через Value
+		if value, err := v.Value(); err == nil {
+			return csvValue(value)
+		}
+		return fmt.Sprint(v)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgtype"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestCSVValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"null", nil, ""},
+		{"string", "a,b", "a,b"},
+		{"bytes", []byte("raw"), "raw"},
+		{"int", int64(42), "42"},
+		{"bool", true, "true"},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+		{"uuid", [16]byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0, 1, 2, 3, 4, 5, 6, 7, 8}, "12345678-9abc-def0-0102-030405060708"},
+		{"numeric", pgtype.Numeric{Int: big.NewInt(12345), Exp: -2, Valid: true}, "123.45"},
+	}
+
+	for _, tt := range tests {
+		if got := csvValue(tt.value); got != tt.want {
+			t.Errorf("%s: csvValue() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryCSV(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"id", "name", "note"}, rows: [][]any{
+		{int64(1), "Ann", nil},
+		{int64(2), "Smith, John", `say "hi"`},
+	}}
+
+	var buf bytes.Buffer
+	if err := QueryCSV(context.Background(), db, &buf, "SELECT id, name, note FROM users"); err != nil {
+		t.Fatalf("QueryCSV() error = %v", err)
+	}
+
+	want := "id,name,note\n1,Ann,\n2,\"Smith, John\",\"say \"\"hi\"\"\"\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("QueryCSV() wrote %q, want %q", got, want)
+	}
+}
+
+func TestQueryCSVQueryError(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	var buf bytes.Buffer
+	if err := QueryCSV(context.Background(), &fakeQuerier{err: errBroken}, &buf, "SELECT 1"); !errors.Is(err, errBroken) {
+		t.Fatalf("QueryCSV() error = %v, want %v", err, errBroken)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("QueryCSV() wrote %q after a failed query", buf.String())
+	}
+}
+
+func TestQueryCSVOnServer(t *testing.T) {
+	pool := testPool(t)
+
+	var buf bytes.Buffer
+	err := QueryCSV(context.Background(), pool, &buf,
+		"SELECT g AS id, CASE WHEN g = 2 THEN NULL ELSE 'name, ' || g END AS name, g * 1.5::numeric AS amount FROM generate_series(1, 3) g")
+	if err != nil {
+		t.Fatalf("QueryCSV() error = %v", err)
+	}
+
+	want := "id,name,amount\n1,\"name, 1\",1.5\n2,,3.0\n3,\"name, 3\",4.5\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("QueryCSV() wrote %q, want %q", got, want)
+	}
+}
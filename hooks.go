@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueryObserver получает итог каждого запроса: пул или транзакцию, операцию, длительность и ошибку
+type QueryObserver func(db Querier, op string, duration time.Duration, err error)
+
+// registeredObserver наблюдатель с номером, по которому его можно снять
+type registeredObserver struct {
+	id uint64
+	fn QueryObserver
+}
+
+var (
+	observersMu    sync.Mutex
+	observers      atomic.Pointer[[]registeredObserver]
+	nextObserverID uint64
+)
+
+// AddQueryObserver регистрирует наблюдателя, который вызывается после каждого запроса хелперов.
+// Возвращает функцию, снимающую наблюдателя; повторный вызов ничего не делает
+func AddQueryObserver(fn QueryObserver) (remove func()) {
+	observersMu.Lock()
+	defer observersMu.Unlock()
+
+	nextObserverID++
+	id := nextObserverID
+
+	var next []registeredObserver
+	if cur := observers.Load(); cur != nil {
+		next = append(next, *cur...)
+	}
+	next = append(next, registeredObserver{id: id, fn: fn})
+
+	observers.Store(&next)
+
+	return func() { removeQueryObserver(id) }
+}
+
+// removeQueryObserver снимает наблюдателя с номером id
+func removeQueryObserver(id uint64) {
+	observersMu.Lock()
+	defer observersMu.Unlock()
+
+	cur := observers.Load()
+	if cur == nil {
+		return
+	}
+
+	next := make([]registeredObserver, 0, len(*cur))
+	for _, o := range *cur {
+		if o.id != id {
+			next = append(next, o)
+		}
+	}
+
+	observers.Store(&next)
+}
+
+// notifyObservers передает итог запроса всем наблюдателям
+func notifyObservers(db Querier, op string, duration time.Duration, err error) {
+	cur := observers.Load()
+	if cur == nil {
+		return
+	}
+
+	for _, o := range *cur {
+		o.fn(db, op, duration, err)
+	}
+}
+
+type slowQueryHook struct {
+	threshold time.Duration
+	fn        func(sql string, d time.Duration)
+}
+
+var slowHook atomic.Pointer[slowQueryHook]
+
+// SetSlowQueryHook задает функцию, которая вызывается для запросов дольше threshold.
+// Передача nil в fn отключает хук
+func SetSlowQueryHook(threshold time.Duration, fn func(sql string, d time.Duration)) {
+	if fn == nil {
+		slowHook.Store(nil)
+		return
+	}
+	slowHook.Store(&slowQueryHook{threshold: threshold, fn: fn})
+}
+
+// notifySlowQuery вызывает хук медленных запросов, если запрос превысил порог
+func notifySlowQuery(sql string, duration time.Duration) {
+	if h := slowHook.Load(); h != nil && duration > h.threshold {
+		h.fn(sql, duration)
+	}
+}
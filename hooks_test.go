@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAddQueryObserver(t *testing.T) {
+	db := &fakeQuerier{err: errors.New("boom"), failOn: "DELETE FROM users"}
+
+	type observed struct {
+		op     string
+		failed bool
+	}
+	var got []observed
+	remove := AddQueryObserver(func(q Querier, op string, duration time.Duration, err error) {
+		if q == Querier(db) {
+			got = append(got, observed{op: op, failed: err != nil})
+		}
+	})
+
+	ctx := context.Background()
+	_ = Exec(ctx, db, "UPDATE users SET name = 'a'")
+	_ = Exec(ctx, db, "DELETE FROM users")
+
+	remove()
+	_ = Exec(ctx, db, "UPDATE users SET name = 'b'")
+
+	want := []observed{{"Exec", false}, {"Exec", true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("observed %v, want %v", got, want)
+	}
+}
+
+func TestRemoveQueryObserverKeepsOthers(t *testing.T) {
+	db := &fakeQuerier{}
+
+	var first, second int
+	removeFirst := AddQueryObserver(func(q Querier, op string, duration time.Duration, err error) {
+		if q == Querier(db) {
+			first++
+		}
+	})
+	removeSecond := AddQueryObserver(func(q Querier, op string, duration time.Duration, err error) {
+		if q == Querier(db) {
+			second++
+		}
+	})
+	defer removeSecond()
+
+	removeFirst()
+	removeFirst()
+	_ = Exec(context.Background(), db, "SELECT 1")
+
+	if first != 0 || second != 1 {
+		t.Fatalf("first observer called %d times, second %d times, want 0 and 1", first, second)
+	}
+}
+
+// useSlowQueryHook задает хук медленных запросов, который собирает SQL, и снимает его после теста
+func useSlowQueryHook(t *testing.T, threshold time.Duration) *[]string {
+	t.Helper()
+
+	var slow []string
+	SetSlowQueryHook(threshold, func(sql string, d time.Duration) {
+		slow = append(slow, sql)
+	})
+	t.Cleanup(func() { SetSlowQueryHook(0, nil) })
+
+	return &slow
+}
+
+func TestSlowQueryHookThreshold(t *testing.T) {
+	ctx := context.Background()
+	db := &fakeQuerier{}
+
+	slow := useSlowQueryHook(t, time.Hour)
+	_ = Exec(ctx, db, "UPDATE users SET name = 'a'")
+	if len(*slow) != 0 {
+		t.Fatalf("hook fired for a query below the threshold: %q", *slow)
+	}
+
+	slow = useSlowQueryHook(t, 0)
+	_ = Exec(ctx, db, "UPDATE users SET name = 'b'")
+	if want := []string{"UPDATE users SET name = 'b'"}; !reflect.DeepEqual(*slow, want) {
+		t.Fatalf("hook got %q, want %q", *slow, want)
+	}
+
+	SetSlowQueryHook(0, nil)
+	_ = Exec(ctx, db, "UPDATE users SET name = 'c'")
+	if len(*slow) != 1 {
+		t.Fatalf("disabled hook still fired: %q", *slow)
+	}
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	slow := useSlowQueryHook(t, 100*time.Millisecond)
+
+	if _, err := QueryOne[int](ctx, pool, "SELECT 1"); err != nil {
+		t.Fatalf("fast query error = %v", err)
+	}
+	if err := Exec(ctx, pool, "SELECT pg_sleep(0.3)"); err != nil {
+		t.Fatalf("slow query error = %v", err)
+	}
+
+	if want := []string{"SELECT pg_sleep(0.3)"}; !reflect.DeepEqual(*slow, want) {
+		t.Fatalf("hook got %q, want only the slow query", *slow)
+	}
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReleaseFunc снимает блокировку и возвращает соединение в пул; повторные вызовы ничего не делают
+type ReleaseFunc func(ctx context.Context) error
+
+// LockWait описывает ожидание блокировки: какой процесс кем заблокирован
+type LockWait struct {
+	BlockedPID    int32  `db:"blocked_pid"`
+	BlockedQuery  string `db:"blocked_query"`
+	BlockingPID   int32  `db:"blocking_pid"`
+	BlockingQuery string `db:"blocking_query"`
+}
+
+const blockingLocksSQL = `SELECT blocked.pid AS blocked_pid, blocked.query AS blocked_query,
+	blocking.pid AS blocking_pid, blocking.query AS blocking_query
+FROM pg_stat_activity blocked
+CROSS JOIN LATERAL unnest(pg_blocking_pids(blocked.pid)) AS b(pid)
+JOIN pg_stat_activity blocking ON blocking.pid = b.pid
+ORDER BY blocked.pid, blocking.pid`
+
+// BlockingLocks возвращает граф ожидания блокировок по pg_stat_activity и pg_blocking_pids
+func BlockingLocks(ctx context.Context, db Querier) ([]LockWait, error) {
+	return QueryStructs[LockWait](ctx, db, blockingLocksSQL)
+}
+
+// TryAdvisoryLock пытается взять сессионную advisory-блокировку key без ожидания.
+// Блокировка держится на выделенном соединении до вызова release; если блокировка занята, acquired = false
+func TryAdvisoryLock(ctx context.Context, pool *pgxpool.Pool, key int64) (acquired bool, release ReleaseFunc, err error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	if err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Release()
+		return false, nil, fmt.Errorf("failed to try advisory lock %d: %w", key, err)
+	}
+
+	if !acquired {
+		conn.Release()
+		return false, nil, nil
+	}
+
+	return true, advisoryUnlocker(conn, key), nil
+}
+
+// AdvisoryLock ждет сессионную advisory-блокировку key (или отмены ctx) и держит ее на выделенном соединении до вызова release
+func AdvisoryLock(ctx context.Context, pool *pgxpool.Pool, key int64) (ReleaseFunc, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to take advisory lock %d: %w", key, err)
+	}
+
+	return advisoryUnlocker(conn, key), nil
+}
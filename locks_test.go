@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"testing"
+	"time"
+)
+
+func TestBlockingLocks(t *testing.T) {
+	pool := testPool(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	table := testTable(t, pool, "id int")
+
+	holder, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	defer func() { _ = holder.Rollback(context.Background()) }()
+
+	var holderPID int32
+	if err = holder.QueryRow(ctx, "SELECT pg_backend_pid()").Scan(&holderPID); err != nil {
+		t.Fatalf("failed to get backend pid: %v", err)
+	}
+	mustExec(t, holder, "LOCK TABLE "+table+" IN ACCESS EXCLUSIVE MODE")
+
+	waiterDone := make(chan error, 1)
+	go func() {
+		waiterDone <- WithTransaction(ctx, pool, func(tx pgx.Tx) error {
+			_, err := tx.Exec(ctx, "LOCK TABLE "+table+" IN ACCESS EXCLUSIVE MODE")
+			return err
+		})
+	}()
+
+	for !isBlocking(t, ctx, pool, holderPID) {
+		select {
+		case <-ctx.Done():
+			t.Fatal("waiting transaction did not show up in BlockingLocks")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	if err = holder.Rollback(ctx); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if err = <-waiterDone; err != nil {
+		t.Fatalf("waiting transaction error = %v", err)
+	}
+}
+
+// isBlocking сообщает, что процесс pid блокирует кого-то по данным BlockingLocks
+func isBlocking(t *testing.T, ctx context.Context, db Querier, pid int32) bool {
+	t.Helper()
+
+	waits, err := BlockingLocks(ctx, db)
+	if err != nil {
+		t.Fatalf("BlockingLocks() error = %v", err)
+	}
+	for _, w := range waits {
+		if w.BlockingPID == pid {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestTryAdvisoryLockIsExclusive(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	key := time.Now().UnixNano()
+
+	acquired, release, err := TryAdvisoryLock(ctx, pool, key)
+	if err != nil {
+		t.Fatalf("TryAdvisoryLock() error = %v", err)
+	}
+	if !acquired {
+		t.Fatal("first TryAdvisoryLock() acquired = false")
+	}
+
+	acquired, _, err = TryAdvisoryLock(ctx, pool, key)
+	if err != nil {
+		t.Fatalf("second TryAdvisoryLock() error = %v", err)
+	}
+	if acquired {
+		t.Fatal("second TryAdvisoryLock() acquired the held lock")
+	}
+
+	if err = release(ctx); err != nil {
+		t.Fatalf("release() error = %v", err)
+	}
+	if err = release(ctx); err != nil {
+		t.Fatalf("repeated release() error = %v", err)
+	}
+
+	acquired, release, err = TryAdvisoryLock(ctx, pool, key)
+	if err != nil {
+		t.Fatalf("TryAdvisoryLock() after release error = %v", err)
+	}
+	if !acquired {
+		t.Fatal("TryAdvisoryLock() after release acquired = false")
+	}
+	_ = release(ctx)
+}
+
+func TestAdvisoryLockWaitsForRelease(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	key := time.Now().UnixNano()
+
+	release, err := AdvisoryLock(ctx, pool, key)
+	if err != nil {
+		t.Fatalf("AdvisoryLock() error = %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if _, err = AdvisoryLock(waitCtx, pool, key); err == nil {
+		t.Fatal("AdvisoryLock() took a held lock")
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		second, err := AdvisoryLock(ctx, pool, key)
+		if err == nil {
+			err = second(ctx)
+		}
+		acquired <- err
+	}()
+
+	select {
+	case err = <-acquired:
+		t.Fatalf("AdvisoryLock() returned before release: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err = release(ctx); err != nil {
+		t.Fatalf("release() error = %v", err)
+	}
+	if err = <-acquired; err != nil {
+		t.Fatalf("AdvisoryLock() after release error = %v", err)
+	}
+}
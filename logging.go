@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"gitlab.com/nevasik7/lg"
+)
+
+// logInfof, logWarnf и logErrorf пишут сообщения хелперов запросов в lg; тесты подменяют их, чтобы проверить вывод
+var (
+	logInfof  = func(format string, args ...any) { lg.Infof(format, args...) }
+	logWarnf  = func(format string, args ...any) { lg.Warnf(format, args...) }
+	logErrorf = func(format string, args ...any) { lg.Errorf(format, args...) }
+)
+
+type silentKey struct{}
+
+type requestIDKey struct{}
+
+// WithSilent отключает лог времени выполнения для запросов с этим контекстом,
+// например для частых проверок здоровья. Ошибки и прочие сообщения по-прежнему логируются
+func WithSilent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, silentKey{}, true)
+}
+
+func silentEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(silentKey{}).(bool)
+	return enabled
+}
+
+// WithRequestID добавляет идентификатор запроса, который попадает в логи хелперов для корреляции
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID возвращает идентификатор, заданный через WithRequestID
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// withRequestID дописывает к формату лога идентификатор запроса, если он есть в контексте
+func withRequestID(ctx context.Context, format string, args []any) (string, []any) {
+	if id, ok := RequestID(ctx); ok {
+		return format + " [request_id=%s]", append(args, id)
+	}
+	return format, args
+}
+
+// logTiming пишет лог времени выполнения, если он не отключен через WithSilent.
+// Для отмененного контекста и запроса с ошибкой лог не пишется: отмену и ошибку логирует startQuery
+func logTiming(ctx context.Context, err error, format string, args ...any) {
+	if err != nil || silentEnabled(ctx) || ctx.Err() != nil {
+		return
+	}
+	format, args = withRequestID(ctx, format, args)
+	logInfof(format, args...)
+}
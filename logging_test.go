@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// capturedLogs сообщения, перехваченные captureLogs, по уровням
+type capturedLogs struct {
+	mu     sync.Mutex
+	infos  []string
+	warns  []string
+	errors []string
+}
+
+func (c *capturedLogs) add(level *[]string) func(format string, args ...any) {
+	return func(format string, args ...any) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		*level = append(*level, fmt.Sprintf(format, args...))
+	}
+}
+
+func (c *capturedLogs) snapshot() (infos, warns, errs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.infos...), append([]string(nil), c.warns...), append([]string(nil), c.errors...)
+}
+
+// captureLogs перехватывает логи хелперов до конца теста
+func captureLogs(t *testing.T) *capturedLogs {
+	t.Helper()
+
+	logs := &capturedLogs{}
+	prevInfo, prevWarn, prevError := logInfof, logWarnf, logErrorf
+	logInfof, logWarnf, logErrorf = logs.add(&logs.infos), logs.add(&logs.warns), logs.add(&logs.errors)
+	t.Cleanup(func() {
+		logInfof, logWarnf, logErrorf = prevInfo, prevWarn, prevError
+	})
+
+	return logs
+}
+
+func TestWithSilentSuppressesTimingLog(t *testing.T) {
+	logs := captureLogs(t)
+	db := &fakeQuerier{columns: []string{"n"}, rows: [][]any{{1}}}
+
+	if _, err := QuerySimple[int](WithSilent(context.Background()), db, "SELECT 1"); err != nil {
+		t.Fatalf("silent QuerySimple() error = %v", err)
+	}
+	if infos, warns, errs := logs.snapshot(); len(infos)+len(warns)+len(errs) != 0 {
+		t.Fatalf("silent call logged %q %q %q", infos, warns, errs)
+	}
+
+	if _, err := QuerySimple[int](context.Background(), db, "SELECT 1"); err != nil {
+		t.Fatalf("QuerySimple() error = %v", err)
+	}
+	if infos, _, _ := logs.snapshot(); len(infos) != 1 {
+		t.Fatalf("normal call logged %q, want one timing line", infos)
+	}
+}
+
+func TestWithSilentKeepsErrors(t *testing.T) {
+	logs := captureLogs(t)
+	db := &fakeQuerier{err: fmt.Errorf("broken")}
+
+	if _, err := QuerySimple[int](WithSilent(context.Background()), db, "SELECT 1"); err == nil {
+		t.Fatal("QuerySimple() error = nil")
+	}
+	if _, _, errs := logs.snapshot(); len(errs) != 1 {
+		t.Fatalf("silent failing call logged errors %q, want one", errs)
+	}
+}
+
+func TestFailedQueryLogsSQLSTATE(t *testing.T) {
+	logs := captureLogs(t)
+	db := &fakeQuerier{err: &pgconn.PgError{Code: "42P01", Message: `relation "missing" does not exist`}}
+
+	if _, err := QuerySimple[int](context.Background(), db, "SELECT id FROM missing"); err == nil {
+		t.Fatal("QuerySimple() error = nil")
+	}
+
+	infos, _, errs := logs.snapshot()
+	if len(infos) != 0 {
+		t.Fatalf("failed query logged timing %q", infos)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], "SQLSTATE 42P01") || !strings.Contains(errs[0], "SELECT id FROM missing") {
+		t.Fatalf("error logs = %q, want one line with the SQLSTATE and statement", errs)
+	}
+}
+
+func TestFailedQueryLogWithoutSQLSTATE(t *testing.T) {
+	logs := captureLogs(t)
+	db := &fakeQuerier{err: fmt.Errorf("connection reset")}
+
+	_ = Exec(context.Background(), db, "DELETE FROM users")
+
+	_, _, errs := logs.snapshot()
+	if len(errs) != 1 || strings.Contains(errs[0], "SQLSTATE") || !strings.Contains(errs[0], "connection reset") {
+		t.Fatalf("error logs = %q, want one line with the error and no SQLSTATE", errs)
+	}
+}
+
+func TestFailedQueryLogOnServer(t *testing.T) {
+	pool := testPool(t)
+	logs := captureLogs(t)
+
+	if _, err := QuerySimple[int](context.Background(), pool, "SELECT 1/0"); err == nil {
+		t.Fatal("QuerySimple() error = nil for division by zero")
+	}
+
+	_, _, errs := logs.snapshot()
+	if len(errs) != 1 || !strings.Contains(errs[0], "SQLSTATE 22012") {
+		t.Fatalf("error logs = %q, want one line with SQLSTATE 22012", errs)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if _, ok := RequestID(context.Background()); ok {
+		t.Fatal("RequestID() ok = true without an id")
+	}
+	if _, ok := RequestID(WithRequestID(context.Background(), "")); ok {
+		t.Fatal("RequestID() ok = true for an empty id")
+	}
+	if id, ok := RequestID(WithRequestID(context.Background(), "req-1")); !ok || id != "req-1" {
+		t.Fatalf("RequestID() = %q, %v, want req-1", id, ok)
+	}
+}
+
+func TestRequestIDInLogs(t *testing.T) {
+	logs := captureLogs(t)
+	ctx := WithRequestID(context.Background(), "req-42")
+
+	_ = Exec(ctx, &fakeQuerier{}, "UPDATE users SET name = 'a'")
+	_ = Exec(ctx, &fakeQuerier{err: fmt.Errorf("broken")}, "UPDATE users SET name = 'b'")
+	_ = Exec(context.Background(), &fakeQuerier{}, "UPDATE users SET name = 'c'")
+
+	infos, _, errs := logs.snapshot()
+	if len(infos) != 2 || len(errs) != 1 {
+		t.Fatalf("logs = %q %q, want two timing lines and one error", infos, errs)
+	}
+	if !strings.HasSuffix(infos[0], "[request_id=req-42]") || !strings.HasSuffix(errs[0], "[request_id=req-42]") {
+		t.Fatalf("logs = %q %q, want the request id appended", infos[0], errs[0])
+	}
+	if strings.Contains(infos[1], "request_id") {
+		t.Fatalf("log without a request id = %q", infos[1])
+	}
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"gitlab.com/nevasik7/postgres"
+	"time"
+)
+
+// Collector метрики запросов и пула подключений для Prometheus.
+// Вынесен в отдельный пакет, чтобы основной пакет не зависел от клиента Prometheus
+type Collector struct {
+	pool       *pgxpool.Pool
+	unregister func()
+
+	queries  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	acquiredConns   *prometheus.Desc
+	idleConns       *prometheus.Desc
+	totalConns      *prometheus.Desc
+	maxConns        *prometheus.Desc
+	acquireCount    *prometheus.Desc
+	acquireDuration *prometheus.Desc
+}
+
+// NewCollector создает коллектор для пула и подписывает его на запросы хелперов postgres; подписку снимает Close
+func NewCollector(pool *pgxpool.Pool) *Collector {
+	c := &Collector{
+		pool: pool,
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "postgres_queries_total",
+			Help: "Number of executed queries by operation.",
+		}, []string{"operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "postgres_query_errors_total",
+			Help: "Number of failed queries by operation.",
+		}, []string{"operation"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "postgres_query_duration_seconds",
+			Help:    "Query duration by operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation"}),
+		acquiredConns:   prometheus.NewDesc("postgres_pool_acquired_conns", "Number of currently acquired connections.", nil, nil),
+		idleConns:       prometheus.NewDesc("postgres_pool_idle_conns", "Number of idle connections.", nil, nil),
+		totalConns:      prometheus.NewDesc("postgres_pool_total_conns", "Total number of connections in the pool.", nil, nil),
+		maxConns:        prometheus.NewDesc("postgres_pool_max_conns", "Maximum size of the pool.", nil, nil),
+		acquireCount:    prometheus.NewDesc("postgres_pool_acquire_total", "Number of successful connection acquires.", nil, nil),
+		acquireDuration: prometheus.NewDesc("postgres_pool_acquire_duration_seconds_total", "Total time spent acquiring connections.", nil, nil),
+	}
+
+	c.unregister = postgres.AddQueryObserver(c.observe)
+
+	return c
+}
+
+// Close отписывает коллектор от запросов хелперов; вызывайте вместе с закрытием пула
+func (c *Collector) Close() {
+	c.unregister()
+}
+
+// Describe реализует prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.queries.Describe(ch)
+	c.errors.Describe(ch)
+	c.duration.Describe(ch)
+
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquireCount
+	ch <- c.acquireDuration
+}
+
+// Collect реализует prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.queries.Collect(ch)
+	c.errors.Collect(ch)
+	c.duration.Collect(ch)
+
+	s := postgres.PoolStats(c.pool)
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(s.AcquiredConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(s.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(s.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(s.MaxConns))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(s.AcquireCount))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.AcquireDuration.Seconds())
+}
+
+// observe учитывает запросы только своего пула, в том числе через *postgres.DB, транзакции и соединения из него
+func (c *Collector) observe(db postgres.Querier, op string, duration time.Duration, err error) {
+	if pool, ok := postgres.PoolOf(db); !ok || pool != c.pool {
+		return
+	}
+
+	c.queries.WithLabelValues(op).Inc()
+	c.duration.WithLabelValues(op).Observe(duration.Seconds())
+	if err != nil {
+		c.errors.WithLabelValues(op).Inc()
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	dto "github.com/prometheus/client_model/go"
+	"gitlab.com/nevasik7/postgres"
+	"testing"
+	"time"
+)
+
+// newPool создает пул к адресу, на котором никто не слушает: запросы через него быстро завершаются ошибкой
+func newPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := postgres.NewDB(context.Background(), &postgres.DBConfig{Host: "127.0.0.1", Port: "1", Db: "test", SslMode: "disable"},
+		postgres.WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func counterValue(t *testing.T, c interface{ Write(*dto.Metric) error }) float64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("failed to read metric: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestCollectorCountsOwnPoolOnly(t *testing.T) {
+	pool := newPool(t)
+	other := newPool(t)
+
+	c := NewCollector(pool)
+	defer c.Close()
+
+	ctx := context.Background()
+	_ = postgres.Exec(ctx, pool, "SELECT 1")
+	_ = postgres.Exec(ctx, other, "SELECT 1")
+
+	if got := counterValue(t, c.queries.WithLabelValues("Exec")); got != 1 {
+		t.Fatalf("queries = %v, want 1", got)
+	}
+	if got := counterValue(t, c.errors.WithLabelValues("Exec")); got != 1 {
+		t.Fatalf("errors = %v, want 1", got)
+	}
+}
+
+func TestCollectorCountsQueriesThroughDB(t *testing.T) {
+	db, err := postgres.OpenDB(context.Background(), &postgres.DBConfig{Host: "127.0.0.1", Port: "1", Db: "test", SslMode: "disable"},
+		postgres.WithConnectTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("OpenDB() error = %v", err)
+	}
+	defer db.Close()
+
+	c := NewCollector(db.Pool())
+	defer c.Close()
+
+	_ = postgres.Exec(context.Background(), db, "SELECT 1")
+
+	if got := counterValue(t, c.queries.WithLabelValues("Exec")); got != 1 {
+		t.Fatalf("queries through *DB = %v, want 1", got)
+	}
+}
+
+func TestCollectorClose(t *testing.T) {
+	pool := newPool(t)
+	c := NewCollector(pool)
+
+	ctx := context.Background()
+	_ = postgres.Exec(ctx, pool, "SELECT 1")
+	c.Close()
+	_ = postgres.Exec(ctx, pool, "SELECT 1")
+
+	if got := counterValue(t, c.queries.WithLabelValues("Exec")); got != 1 {
+		t.Fatalf("queries = %v, want 1: the collector must stop counting after Close", got)
+	}
+}
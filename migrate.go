@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/nevasik7/lg"
+	"io/fs"
+	"time"
+)
+
+// migrationsLockKey ключ advisory lock, не дающий нескольким экземплярам применять миграции одновременно
+const migrationsLockKey int64 = 7_365_812_017
+
+// RunMigrations применяет SQL-миграции из каталога dir файловой системы fsys.
+// Файлы именуются с номера версии ("0001_init.sql"), версии идут подряд с 1 без пропусков, примененные версии хранятся в schema_migrations,
+// каждая миграция выполняется в своей транзакции. Если примененной версии нет среди файлов или
+// новая миграция имеет номер меньше уже примененной, возвращается ошибка
+func RunMigrations(ctx context.Context, pool *pgxpool.Pool, fsys fs.FS, dir string) (err error) {
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed migrations from %s in %s", dir, elapsed)
+	}()
+
+	migrations, err := readMigrations(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	return WithConn(ctx, pool, func(conn *pgxpool.Conn) error {
+		if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+		defer func() {
+			_, _ = conn.Exec(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", migrationsLockKey)
+		}()
+
+		_, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`)
+		if err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+
+		rows, err := conn.Query(ctx, "SELECT version FROM schema_migrations")
+		if err != nil {
+			return fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		applied, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+
+		pending, err := pendingMigrations(migrations, applied)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range pending {
+			if err = applyMigration(ctx, conn, m); err != nil {
+				return err
+			}
+			lg.Infof("Applied migration %s", m.name)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestReadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_email.sql": {Data: []byte("ALTER TABLE users ADD email text")},
+		"migrations/0001_init.sql":      {Data: []byte("CREATE TABLE users (id int)")},
+		"migrations/README.md":          {Data: []byte("notes")},
+	}
+
+	migrations, err := readMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("readMigrations() error = %v", err)
+	}
+
+	want := []migration{
+		{version: 1, name: "0001_init.sql", sql: "CREATE TABLE users (id int)"},
+		{version: 2, name: "0002_add_email.sql", sql: "ALTER TABLE users ADD email text"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Fatalf("readMigrations() = %+v, want %+v", migrations, want)
+	}
+}
+
+func TestReadMigrationsErrors(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"no version": {"migrations/init.sql": {}},
+		"duplicate version": {
+			"migrations/0001_a.sql": {},
+			"migrations/1_b.sql":    {},
+		},
+	}
+
+	for name, fsys := range tests {
+		if _, err := readMigrations(fsys, "migrations"); err == nil {
+			t.Errorf("%s: readMigrations() error = nil", name)
+		}
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	migrations := []migration{{version: 1, name: "1.sql"}, {version: 2, name: "2.sql"}, {version: 3, name: "3.sql"}}
+
+	pending, err := pendingMigrations(migrations, []int64{1})
+	if err != nil {
+		t.Fatalf("pendingMigrations() error = %v", err)
+	}
+	if want := migrations[1:]; !reflect.DeepEqual(pending, want) {
+		t.Fatalf("pendingMigrations() = %+v, want %+v", pending, want)
+	}
+}
+
+func TestPendingMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []migration
+		applied    []int64
+	}{
+		{"gap in versions", []migration{{version: 1}, {version: 3}}, nil},
+		{"does not start at 1", []migration{{version: 2}, {version: 3}}, nil},
+		{"applied version is missing", []migration{{version: 1}}, []int64{1, 2}},
+		{"out of order", []migration{{version: 1}, {version: 2}, {version: 3}}, []int64{1, 3}},
+	}
+
+	for _, tt := range tests {
+		if _, err := pendingMigrations(tt.migrations, tt.applied); err == nil {
+			t.Errorf("%s: pendingMigrations() error = nil", tt.name)
+		}
+	}
+}
+
+func TestRunMigrations(t *testing.T) {
+	admin := testPool(t)
+	ctx := context.Background()
+
+	schema := fmt.Sprintf("test_migrations_%d", time.Now().UnixNano())
+	mustExec(t, admin, "CREATE SCHEMA "+schema)
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+	})
+
+	pool := testPool(t, WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, "SET search_path TO "+schema)
+		return err
+	}))
+
+	fsys := fstest.MapFS{
+		"m/0001_init.sql":  {Data: []byte("CREATE TABLE users (id int PRIMARY KEY)")},
+		"m/0002_email.sql": {Data: []byte("ALTER TABLE users ADD email text")},
+	}
+	if err := RunMigrations(ctx, pool, fsys, "m"); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+
+	fsys["m/0003_name.sql"] = &fstest.MapFile{Data: []byte("ALTER TABLE users ADD name text")}
+	if err := RunMigrations(ctx, pool, fsys, "m"); err != nil {
+		t.Fatalf("RunMigrations() second run error = %v", err)
+	}
+
+	versions, err := QuerySimple[int64](ctx, pool, "SELECT version FROM schema_migrations ORDER BY version")
+	if err != nil {
+		t.Fatalf("QuerySimple() error = %v", err)
+	}
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(versions, want) {
+		t.Fatalf("applied versions = %v, want %v", versions, want)
+	}
+
+	mustExec(t, pool, "INSERT INTO users (id, email, name) VALUES (1, 'a@example.com', 'Ann')")
+}
+
+func TestRunMigrationsRollsBackFailedMigration(t *testing.T) {
+	admin := testPool(t)
+	ctx := context.Background()
+
+	schema := fmt.Sprintf("test_migrations_%d", time.Now().UnixNano())
+	mustExec(t, admin, "CREATE SCHEMA "+schema)
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+	})
+
+	pool := testPool(t, WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, "SET search_path TO "+schema)
+		return err
+	}))
+
+	fsys := fstest.MapFS{
+		"m/0001_init.sql":   {Data: []byte("CREATE TABLE users (id int)")},
+		"m/0002_broken.sql": {Data: []byte("CREATE TABLE broken (id int); SELECT no_such_column FROM users")},
+	}
+	if err := RunMigrations(ctx, pool, fsys, "m"); err == nil {
+		t.Fatal("RunMigrations() error = nil for a broken migration")
+	}
+
+	exists, err := Exists(ctx, pool, "SELECT 1 FROM pg_tables WHERE schemaname = $1 AND tablename = 'broken'", schema)
+	if err != nil {
+		t.Fatalf("Exists() error = %v", err)
+	}
+	if exists {
+		t.Fatal("broken migration was not rolled back")
+	}
+}
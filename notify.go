@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/nevasik7/lg"
+	"time"
+)
+
+// listenReconnectDelay - пауза перед повторной подпиской после потери соединения;
+// пока база недоступна, пауза удваивается до listenMaxReconnectDelay
+const (
+	listenReconnectDelay    = time.Second
+	listenMaxReconnectDelay = 30 * time.Second
+)
+
+// Notification уведомление для отправки через pg_notify
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// Listen подписывается на канал LISTEN/NOTIFY на отдельном соединении и вызывает handler для каждого уведомления.
+// Блокируется до отмены ctx, при потере соединения переподписывается на новом, а пока база недоступна,
+// повторяет подключение с растущей паузой
+func Listen(ctx context.Context, pool *pgxpool.Pool, channel string, handler func(payload string)) error {
+	delay := listenReconnectDelay
+	for {
+		acquired := false
+		err := WithConn(ctx, pool, func(conn *pgxpool.Conn) error {
+			acquired = true
+			return listen(ctx, conn, channel, handler)
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		switch {
+		case acquired && isConnError(err):
+			delay = listenReconnectDelay
+			lg.Infof("Lost connection while listening %s, reconnecting: %v", channel, err)
+		case !acquired && (isDialError(err) || isConnError(err)):
+			lg.Warnf("Failed to connect for listening %s, retrying in %s: %v", channel, delay, err)
+		default:
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		if !acquired {
+			delay = min(delay*2, listenMaxReconnectDelay)
+		}
+	}
+}
+
+// NotifyInTx отправляет уведомления в рамках транзакции одним пакетом, схлопывая повторы по (Channel, Payload).
+// Слушатели получат уведомления только после коммита транзакции
+func NotifyInTx(ctx context.Context, tx pgx.Tx, notifications ...Notification) (err error) {
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed notify in tx in %s", elapsed)
+	}()
+
+	seen := make(map[Notification]struct{}, len(notifications))
+	batch := &pgx.Batch{}
+	for _, n := range notifications {
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		batch.Queue("SELECT pg_notify($1, $2)", n.Channel, n.Payload)
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to notify: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestIsDialError(t *testing.T) {
+	pool := unreachablePool(t)
+
+	err := WithConn(context.Background(), pool, func(conn *pgxpool.Conn) error { return nil })
+	if !isDialError(err) {
+		t.Fatalf("isDialError(%v) = false, want true", err)
+	}
+	if isDialError(errors.New("syntax error")) {
+		t.Fatal("isDialError() = true for a plain error")
+	}
+}
+
+func TestListenRetriesWhileDatabaseIsDown(t *testing.T) {
+	pool := unreachablePool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), listenReconnectDelay+500*time.Millisecond)
+	defer cancel()
+
+	err := Listen(ctx, pool, "events", func(payload string) {})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Listen() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestListenReceivesNotifications(t *testing.T) {
+	pool := testPool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- Listen(ctx, pool, "listen_test", func(payload string) {
+			received <- payload
+		})
+	}()
+
+	// уведомление, отправленное до LISTEN, потеряется, поэтому отправляем, пока не дойдет
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case payload := <-received:
+			if payload != "hello" {
+				t.Fatalf("payload = %q, want hello", payload)
+			}
+			cancel()
+			if err := <-done; !errors.Is(err, context.Canceled) {
+				t.Fatalf("Listen() error = %v, want %v", err, context.Canceled)
+			}
+			return
+		case <-ticker.C:
+			mustExec(t, pool, "SELECT pg_notify('listen_test', 'hello')")
+		case <-ctx.Done():
+			t.Fatal("notification was not received")
+		}
+	}
+}
+
+func TestNotifyInTxEmpty(t *testing.T) {
+	if err := NotifyInTx(context.Background(), nil); err != nil {
+		t.Fatalf("NotifyInTx() error = %v, want nil without notifications", err)
+	}
+}
+
+func TestNotifyInTxDeduplicates(t *testing.T) {
+	pool := testPool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listener, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	defer listener.Release()
+	mustExec(t, listener, "LISTEN notify_in_tx_test")
+
+	err = WithTransaction(ctx, pool, func(tx pgx.Tx) error {
+		return NotifyInTx(ctx, tx,
+			Notification{Channel: "notify_in_tx_test", Payload: "a"},
+			Notification{Channel: "notify_in_tx_test", Payload: "b"},
+			Notification{Channel: "notify_in_tx_test", Payload: "a"},
+		)
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+
+	var payloads []string
+	for {
+		waitCtx, waitCancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		n, err := listener.Conn().WaitForNotification(waitCtx)
+		waitCancel()
+		if err != nil {
+			break
+		}
+		payloads = append(payloads, n.Payload)
+	}
+	sort.Strings(payloads)
+
+	if want := []string{"a", "b"}; !reflect.DeepEqual(payloads, want) {
+		t.Fatalf("received %v, want %v", payloads, want)
+	}
+}
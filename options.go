@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/tracelog"
+	"time"
+)
+
+// Option настраивает конфигурацию пула перед его созданием
+type Option func(config *pgxpool.Config)
+
+// WithMaxConns задает максимальный размер пула
+func WithMaxConns(n int32) Option {
+	return func(config *pgxpool.Config) {
+		config.MaxConns = n
+	}
+}
+
+// WithMinConns задает минимальное количество поддерживаемых соединений
+func WithMinConns(n int32) Option {
+	return func(config *pgxpool.Config) {
+		config.MinConns = n
+	}
+}
+
+// WithMaxConnLifetime задает время жизни соединения, после которого оно пересоздается
+func WithMaxConnLifetime(d time.Duration) Option {
+	return func(config *pgxpool.Config) {
+		config.MaxConnLifetime = d
+	}
+}
+
+// WithConnectTimeout задает таймаут установки соединения
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(config *pgxpool.Config) {
+		config.ConnConfig.ConnectTimeout = timeout
+	}
+}
+
+// WithQueryExecMode задает режим выполнения запросов (например, pgx.QueryExecModeSimpleProtocol для pgbouncer)
+func WithQueryExecMode(mode pgx.QueryExecMode) Option {
+	return func(config *pgxpool.Config) {
+		config.ConnConfig.DefaultQueryExecMode = mode
+	}
+}
+
+// WithLogger включает журналирование запросов pgx через переданный логгер
+func WithLogger(logger tracelog.Logger, level tracelog.LogLevel) Option {
+	return func(config *pgxpool.Config) {
+		config.ConnConfig.Tracer = &tracelog.TraceLog{Logger: logger, LogLevel: level}
+	}
+}
+
+// WithAfterConnect добавляет функцию, выполняемую на каждом новом соединении
+// (например, SET application_name или регистрация типов через conn.TypeMap()).
+// Несколько таких опций выполняются в порядке передачи
+func WithAfterConnect(fn func(ctx context.Context, conn *pgx.Conn) error) Option {
+	return func(config *pgxpool.Config) {
+		prev := config.AfterConnect
+		config.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			if prev != nil {
+				if err := prev(ctx, conn); err != nil {
+					return err
+				}
+			}
+			return fn(ctx, conn)
+		}
+	}
+}
+
+// WithRegisteredTypes загружает из базы и регистрирует на каждом соединении пользовательские типы
+// (enum, composite, domain). Для массивов таких типов передайте и имя массива, например "_mood"
+func WithRegisteredTypes(typeNames ...string) Option {
+	return WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		for _, name := range typeNames {
+			t, err := conn.LoadType(ctx, name)
+			if err != nil {
+				return fmt.Errorf("failed to load type %s: %w", name, err)
+			}
+			conn.TypeMap().RegisterType(t)
+		}
+
+		return nil
+	})
+}
+
+// WithAcquireWarning пишет предупреждение, если ожидание соединения из пула заняло больше threshold.
+// Учитываются запросы хелперов, WithConn и AcquireWithTimeout; частые предупреждения говорят об исчерпании пула
+func WithAcquireWarning(threshold time.Duration) Option {
+	return func(config *pgxpool.Config) {
+		prev := config.BeforeAcquire
+		config.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+			if started, ok := acquireStarted(ctx); ok {
+				if wait := time.Since(started); wait > threshold {
+					logWarnf("Waited %s to acquire a connection from the pool", wait)
+				}
+			}
+
+			if prev != nil {
+				return prev(ctx, conn)
+			}
+			return true
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/tracelog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestConfig() *pgxpool.Config {
+	return &pgxpool.Config{ConnConfig: &pgx.ConnConfig{}}
+}
+
+func TestOptions(t *testing.T) {
+	cfg := newTestConfig()
+	for _, opt := range []Option{
+		WithMaxConns(20),
+		WithMinConns(2),
+		WithMaxConnLifetime(time.Hour),
+		WithConnectTimeout(5 * time.Second),
+		WithQueryExecMode(pgx.QueryExecModeSimpleProtocol),
+	} {
+		opt(cfg)
+	}
+
+	if cfg.MaxConns != 20 || cfg.MinConns != 2 || cfg.MaxConnLifetime != time.Hour {
+		t.Fatalf("pool options = %d/%d/%s, want 20/2/1h", cfg.MaxConns, cfg.MinConns, cfg.MaxConnLifetime)
+	}
+	if cfg.ConnConfig.ConnectTimeout != 5*time.Second {
+		t.Fatalf("ConnectTimeout = %s, want 5s", cfg.ConnConfig.ConnectTimeout)
+	}
+	if cfg.ConnConfig.DefaultQueryExecMode != pgx.QueryExecModeSimpleProtocol {
+		t.Fatalf("DefaultQueryExecMode = %v, want simple protocol", cfg.ConnConfig.DefaultQueryExecMode)
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	cfg := newTestConfig()
+	logger := tracelog.LoggerFunc(func(context.Context, tracelog.LogLevel, string, map[string]any) {})
+	WithLogger(logger, tracelog.LogLevelWarn)(cfg)
+
+	tl, ok := cfg.ConnConfig.Tracer.(*tracelog.TraceLog)
+	if !ok || tl.LogLevel != tracelog.LogLevelWarn {
+		t.Fatalf("Tracer = %#v, want tracelog with warn level", cfg.ConnConfig.Tracer)
+	}
+}
+
+func TestOptionsOverrideDBConfig(t *testing.T) {
+	pool, err := NewDB(context.Background(), &DBConfig{Host: "localhost", Db: "test", MaxConn: 5, MaxConnTime: time.Second},
+		WithMaxConns(9))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer pool.Close()
+
+	if got := pool.Config().MaxConns; got != 9 {
+		t.Fatalf("MaxConns = %d, want 9 from the option", got)
+	}
+}
+
+func TestWithAfterConnectRunsInOrder(t *testing.T) {
+	cfg := newTestConfig()
+
+	var calls []string
+	WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		calls = append(calls, "first")
+		return nil
+	})(cfg)
+	WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		calls = append(calls, "second")
+		return nil
+	})(cfg)
+
+	if err := cfg.AfterConnect(context.Background(), nil); err != nil {
+		t.Fatalf("AfterConnect() error = %v", err)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("AfterConnect calls = %v, want %v", calls, want)
+	}
+}
+
+func TestWithAfterConnectStopsOnError(t *testing.T) {
+	cfg := newTestConfig()
+	failed := errors.New("failed")
+
+	called := false
+	WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error { return failed })(cfg)
+	WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		called = true
+		return nil
+	})(cfg)
+
+	if err := cfg.AfterConnect(context.Background(), nil); !errors.Is(err, failed) {
+		t.Fatalf("AfterConnect() error = %v, want %v", err, failed)
+	}
+	if called {
+		t.Fatal("AfterConnect continued after an error")
+	}
+}
+
+func TestWithRegisteredTypes(t *testing.T) {
+	admin := testPool(t)
+	ctx := context.Background()
+
+	typeName := fmt.Sprintf("test_mood_%d", time.Now().UnixNano())
+	mustExec(t, admin, "CREATE TYPE "+typeName+" AS ENUM ('sad', 'ok', 'happy')")
+	t.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), "DROP TYPE IF EXISTS "+typeName)
+	})
+
+	pool := testPool(t, WithRegisteredTypes(typeName, "_"+typeName))
+
+	got, err := QueryOne[[]string](ctx, pool, "SELECT ARRAY['ok', 'happy']::"+typeName+"[]")
+	if err != nil {
+		t.Fatalf("QueryOne() error = %v", err)
+	}
+	if want := []string{"ok", "happy"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryOne() = %v, want %v", got, want)
+	}
+}
+
+func TestWithRegisteredTypesUnknownType(t *testing.T) {
+	pool := testPool(t, WithRegisteredTypes("no_such_type_for_test"))
+
+	if err := pool.Ping(context.Background()); err == nil {
+		t.Fatal("Ping() error = nil, want error for an unknown registered type")
+	}
+}
+
+func TestWithAcquireWarning(t *testing.T) {
+	logs := captureLogs(t)
+	cfg := newTestConfig()
+
+	prevCalls := 0
+	cfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		prevCalls++
+		return false
+	}
+	WithAcquireWarning(100 * time.Millisecond)(cfg)
+
+	longWait := context.WithValue(context.Background(), acquireStartKey{}, time.Now().Add(-time.Second))
+	if cfg.BeforeAcquire(longWait, nil) {
+		t.Fatal("BeforeAcquire() ignored the previous hook's result")
+	}
+	if _, warns, _ := logs.snapshot(); len(warns) != 1 {
+		t.Fatalf("warnings = %q after a long wait, want one", warns)
+	}
+
+	cfg.BeforeAcquire(markAcquireStart(context.Background()), nil)
+	cfg.BeforeAcquire(context.Background(), nil)
+	if _, warns, _ := logs.snapshot(); len(warns) != 1 {
+		t.Fatalf("warnings = %q after short or unmarked waits, want still one", warns)
+	}
+	if prevCalls != 3 {
+		t.Fatalf("previous BeforeAcquire called %d times, want 3", prevCalls)
+	}
+}
+
+func TestWithAcquireWarningOnSaturatedPool(t *testing.T) {
+	pool := testPool(t, WithMaxConns(1), WithAcquireWarning(50*time.Millisecond))
+	logs := captureLogs(t)
+	ctx := context.Background()
+
+	held, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	time.AfterFunc(200*time.Millisecond, held.Release)
+
+	if err = WithConn(ctx, pool, func(conn *pgxpool.Conn) error { return nil }); err != nil {
+		t.Fatalf("WithConn() error = %v", err)
+	}
+
+	if _, warns, _ := logs.snapshot(); len(warns) != 1 {
+		t.Fatalf("warnings = %q for a delayed acquire, want one", warns)
+	}
+}
@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"time"
+)
+
+// PlanHash выполняет EXPLAIN (FORMAT JSON) и возвращает хеш структуры плана без стоимостей и оценок строк
+func PlanHash(ctx context.Context, db Querier, sql string, args ...any) (_ string, err error) {
+	statement := "EXPLAIN (FORMAT JSON) " + sql
+	ctx, finish, err := startQuery(ctx, db, "PlanHash", statement)
+	if err != nil {
+		return "", err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed plan hash for %s in %s", sql, elapsed)
+	}()
+
+	var raw []byte
+	if err := db.QueryRow(ctx, statement, args...).Scan(&raw); err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	var plan any
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return "", fmt.Errorf("failed to decode plan: %w", err)
+	}
+
+	normalized, err := json.Marshal(normalizePlan(plan))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode plan: %w", err)
+	}
+
+	sum := sha256.Sum256(normalized)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Explain выполняет EXPLAIN (с analyze - EXPLAIN ANALYZE) и возвращает план в текстовом виде.
+// С analyze запрос действительно выполняется, поэтому изменяющие запросы стоит запускать в транзакции с откатом
+func Explain(ctx context.Context, db Querier, sql string, analyze bool, args ...any) (_ string, err error) {
+	statement := explainPrefix(analyze, false) + sql
+	ctx, finish, err := startQuery(ctx, db, "Explain", statement)
+	if err != nil {
+		return "", err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed explain for %s in %s", sql, elapsed)
+	}()
+
+	rows, err := db.Query(ctx, statement, args...)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	lines, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return "", fmt.Errorf("failed to read plan: %w", err)
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// ExplainJSON выполняет EXPLAIN (FORMAT JSON) и возвращает план без разбора
+func ExplainJSON(ctx context.Context, db Querier, sql string, analyze bool, args ...any) (_ json.RawMessage, err error) {
+	statement := explainPrefix(analyze, true) + sql
+	ctx, finish, err := startQuery(ctx, db, "ExplainJSON", statement)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed explain for %s in %s", sql, elapsed)
+	}()
+
+	var raw []byte
+	if err := db.QueryRow(ctx, statement, args...).Scan(&raw); err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+
+	return raw, nil
+}
+
+// explainPrefix строит начало EXPLAIN с нужными опциями
+func explainPrefix(analyze, jsonFormat bool) string {
+	var options []string
+	if analyze {
+		options = append(options, "ANALYZE")
+	}
+	if jsonFormat {
+		options = append(options, "FORMAT JSON")
+	}
+
+	if len(options) == 0 {
+		return "EXPLAIN "
+	}
+	return "EXPLAIN (" + strings.Join(options, ", ") + ") "
+}
@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizePlanDropsVolatileKeys(t *testing.T) {
+	plan := []any{map[string]any{
+		"Plan": map[string]any{
+			"Node Type":    "Index Scan",
+			"Startup Cost": 0.29,
+			"Total Cost":   8.31,
+			"Plan Rows":    1.0,
+			"Plan Width":   12.0,
+			"Plans": []any{map[string]any{
+				"Node Type":  "Seq Scan",
+				"Total Cost": 1.5,
+			}},
+		},
+	}}
+
+	want := []any{map[string]any{
+		"Plan": map[string]any{
+			"Node Type": "Index Scan",
+			"Plans": []any{map[string]any{
+				"Node Type": "Seq Scan",
+			}},
+		},
+	}}
+
+	if got := normalizePlan(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("normalizePlan() = %#v, want %#v", got, want)
+	}
+}
+
+func TestPlanHashIgnoresCostsAndReportsOperation(t *testing.T) {
+	cheap := &fakeQuerier{columns: []string{"QUERY PLAN"}, rows: [][]any{{[]byte(`[{"Plan": {"Node Type": "Seq Scan", "Total Cost": 1.5}}]`)}}}
+	costly := &fakeQuerier{columns: []string{"QUERY PLAN"}, rows: [][]any{{[]byte(`[{"Plan": {"Node Type": "Seq Scan", "Total Cost": 99.0}}]`)}}}
+
+	var ops []string
+	remove := AddQueryObserver(func(q Querier, op string, duration time.Duration, err error) {
+		if q == Querier(cheap) {
+			ops = append(ops, op)
+		}
+	})
+	defer remove()
+
+	ctx := context.Background()
+	first, err := PlanHash(ctx, cheap, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("PlanHash() error = %v", err)
+	}
+	second, err := PlanHash(ctx, costly, "SELECT * FROM users")
+	if err != nil {
+		t.Fatalf("PlanHash() error = %v", err)
+	}
+
+	if first != second {
+		t.Fatalf("PlanHash() differs only by cost: %s != %s", first, second)
+	}
+	if want := []string{"PlanHash"}; !reflect.DeepEqual(ops, want) {
+		t.Fatalf("observed operations %v, want %v", ops, want)
+	}
+	if got, want := cheap.statements(), []string{"EXPLAIN (FORMAT JSON) SELECT * FROM users"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("PlanHash() executed %q, want %q", got, want)
+	}
+}
+
+func TestPlanHashChangesAfterIndexDrop(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	table := testTable(t, pool, "id int NOT NULL, name text")
+	mustExec(t, pool, "INSERT INTO "+table+" SELECT g, 'name' || g FROM generate_series(1, 10000) g")
+	mustExec(t, pool, "CREATE INDEX "+table+"_id_idx ON "+table+" (id)")
+	mustExec(t, pool, "ANALYZE "+table)
+
+	sql := "SELECT name FROM " + table + " WHERE id = $1"
+
+	withIndex, err := PlanHash(ctx, pool, sql, 42)
+	if err != nil {
+		t.Fatalf("PlanHash() error = %v", err)
+	}
+
+	again, err := PlanHash(ctx, pool, sql, 4242)
+	if err != nil {
+		t.Fatalf("PlanHash() error = %v", err)
+	}
+	if again != withIndex {
+		t.Fatalf("PlanHash() is not stable for the same plan: %s != %s", again, withIndex)
+	}
+
+	mustExec(t, pool, "DROP INDEX "+table+"_id_idx")
+
+	withoutIndex, err := PlanHash(ctx, pool, sql, 42)
+	if err != nil {
+		t.Fatalf("PlanHash() error = %v", err)
+	}
+	if withoutIndex == withIndex {
+		t.Fatalf("PlanHash() did not change after dropping the index: %s", withIndex)
+	}
+}
+
+func TestExplainPrefix(t *testing.T) {
+	tests := []struct {
+		analyze, jsonFormat bool
+		want                string
+	}{
+		{false, false, "EXPLAIN "},
+		{true, false, "EXPLAIN (ANALYZE) "},
+		{false, true, "EXPLAIN (FORMAT JSON) "},
+		{true, true, "EXPLAIN (ANALYZE, FORMAT JSON) "},
+	}
+
+	for _, tt := range tests {
+		if got := explainPrefix(tt.analyze, tt.jsonFormat); got != tt.want {
+			t.Errorf("explainPrefix(%v, %v) = %q, want %q", tt.analyze, tt.jsonFormat, got, tt.want)
+		}
+	}
+}
+
+func TestExplainJoinsPlanLines(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"QUERY PLAN"}, rows: [][]any{
+		{"Seq Scan on users  (cost=0.00..1.01 rows=1 width=4)"},
+		{"  Filter: (id = 1)"},
+	}}
+
+	plan, err := Explain(context.Background(), db, "SELECT id FROM users WHERE id = $1", true, 1)
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	if want := "Seq Scan on users  (cost=0.00..1.01 rows=1 width=4)\n  Filter: (id = 1)"; plan != want {
+		t.Fatalf("Explain() = %q, want %q", plan, want)
+	}
+	if got, want := db.statements(), []string{"EXPLAIN (ANALYZE) SELECT id FROM users WHERE id = $1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Explain() executed %q, want %q", got, want)
+	}
+}
+
+func TestExplainReportsOperations(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"QUERY PLAN"}, rows: [][]any{{"Result  (cost=0.00..0.01 rows=1 width=4)"}}}
+	jsonDB := &fakeQuerier{columns: []string{"QUERY PLAN"}, rows: [][]any{{[]byte(`[{"Plan": {"Node Type": "Result"}}]`)}}}
+
+	var ops []string
+	remove := AddQueryObserver(func(q Querier, op string, duration time.Duration, err error) {
+		if q == Querier(db) || q == Querier(jsonDB) {
+			ops = append(ops, op)
+		}
+	})
+	defer remove()
+
+	ctx := context.Background()
+	if _, err := Explain(ctx, db, "SELECT 1", false); err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	if _, err := ExplainJSON(ctx, jsonDB, "SELECT 1", false); err != nil {
+		t.Fatalf("ExplainJSON() error = %v", err)
+	}
+
+	if want := []string{"Explain", "ExplainJSON"}; !reflect.DeepEqual(ops, want) {
+		t.Fatalf("observed operations %v, want %v", ops, want)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY")
+
+	plan, err := Explain(ctx, pool, "SELECT id FROM "+table+" WHERE id = $1", false, 1)
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	if !strings.Contains(plan, table) {
+		t.Fatalf("Explain() = %q, want a plan over %s", plan, table)
+	}
+
+	raw, err := ExplainJSON(ctx, pool, "SELECT id FROM "+table, true)
+	if err != nil {
+		t.Fatalf("ExplainJSON() error = %v", err)
+	}
+
+	var doc []map[string]any
+	if err = json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("ExplainJSON() returned invalid JSON: %v", err)
+	}
+	if len(doc) != 1 || doc[0]["Plan"] == nil || doc[0]["Execution Time"] == nil {
+		t.Fatalf("ExplainJSON() = %s, want an analyzed plan", raw)
+	}
+}
@@ -2,16 +2,991 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"gitlab.com/nevasik7/lg"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
 )
 
-func beginTransaction(ctx context.Context, pool *pgxpool.Pool) (pgx.Tx, error) {
-	tx, err := pool.Begin(ctx)
+// newPool применяет опции к конфигурации и создает пул; зарегистрированные enum-типы загружаются на каждом соединении
+func newPool(ctx context.Context, config *pgxpool.Config, opts []Option) (*pgxpool.Pool, error) {
+	WithAfterConnect(registerEnums)(config)
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+
+	return pool, nil
+}
+
+// beginTransaction открывает транзакцию (в транзакции db - точку сохранения), применяя statement_timeout из контекста.
+// Транзакция пула запоминает пул, чтобы хелперы внутри нее учитывались его размыкателем и наблюдателями
+func beginTransaction(ctx context.Context, db Querier) (pgx.Tx, error) {
+	tx, err := db.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	if pool, ok := PoolOf(db); ok {
+		if _, nested := tx.(*pooledTx); !nested {
+			tx = &pooledTx{Tx: tx, pool: pool}
+		}
+	}
+
+	if timeout, ok := statementTimeout(ctx); ok {
+		if _, err = tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+			_ = tx.Rollback(ctx)
+			return nil, fmt.Errorf("failed to set statement_timeout: %w", err)
+		}
+	}
 
 	return tx, nil
 }
+
+// volatilePlanKeys - поля плана, которые меняются от статистики и не влияют на его форму
+var volatilePlanKeys = map[string]struct{}{
+	"Startup Cost": {},
+	"Total Cost":   {},
+	"Plan Rows":    {},
+	"Plan Width":   {},
+}
+
+// normalizePlan рекурсивно удаляет из плана нестабильные поля
+func normalizePlan(node any) any {
+	switch v := node.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, val := range v {
+			if _, ok := volatilePlanKeys[k]; ok {
+				continue
+			}
+			out[k] = normalizePlan(val)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = normalizePlan(val)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// progressSource считает строки, отданные в COPY, и периодически сообщает о прогрессе
+type progressSource struct {
+	pgx.CopyFromSource
+	every    int64
+	copied   int64
+	progress CopyProgressFunc
+}
+
+func (s *progressSource) Next() bool {
+	if !s.CopyFromSource.Next() {
+		return false
+	}
+
+	s.copied++
+	if s.copied%s.every == 0 {
+		s.progress(s.copied)
+	}
+
+	return true
+}
+
+// retryOnConnError повторяет чтение fn один раз на новом соединении, если ошибка произошла на уровне соединения,
+// а не SQL (в том числе обрыв после отправки: EOF, conn closed). Для изменяющих запросов используйте retryWriteOnConnError.
+// Запросы в транзакции и на выделенном соединении не повторяются: повтор ушел бы в то же мертвое соединение
+func retryOnConnError[T any](ctx context.Context, db Querier, fn func() (T, error)) (T, error) {
+	return retryOnce(db, fn, isConnError)
+}
+
+// retryWriteOnConnError как retryOnConnError, но для изменяющих запросов: без условий повторяется только запрос,
+// который не успел уйти на сервер (pgconn.SafeToRetry); обрыв после отправки повторяется только с WithRetryWrites
+func retryWriteOnConnError[T any](ctx context.Context, db Querier, fn func() (T, error)) (T, error) {
+	return retryOnce(db, fn, func(err error) bool {
+		return pgconn.SafeToRetry(err) || (isConnError(err) && retryWritesEnabled(ctx))
+	})
+}
+
+// retryOnce выполняет fn и повторяет ее один раз, если db выдаст новое соединение и ошибка подходит под canRetry
+func retryOnce[T any](db Querier, fn func() (T, error), canRetry func(err error) bool) (T, error) {
+	res, err := fn()
+	if err == nil || !retryable(db) || !canRetry(err) {
+		return res, err
+	}
+
+	lg.Infof("Retrying after connection error: %v", err)
+	return fn()
+}
+
+// retryable сообщает, что следующий запрос через db получит новое соединение из пула
+func retryable(db Querier) bool {
+	switch db.(type) {
+	case *pgxpool.Pool, *DB:
+		return true
+	}
+	return false
+}
+
+// isConnError сообщает, что ошибка вызвана потерей соединения, а не ответом сервера
+func isConnError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if pgconn.SafeToRetry(err) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "conn busy") || strings.Contains(msg, "conn closed")
+}
+
+// isDialError сообщает, что не удалось установить новое соединение с базой
+func isDialError(err error) bool {
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &connectErr)
+}
+
+// listen выполняет LISTEN на соединении и раздает уведомления, пока не произойдет ошибка
+func listen(ctx context.Context, conn *pgxpool.Conn, channel string, handler func(payload string)) error {
+	ident := pgx.Identifier{channel}.Sanitize()
+	if _, err := conn.Exec(ctx, "LISTEN "+ident); err != nil {
+		return fmt.Errorf("failed to listen %s: %w", channel, err)
+	}
+	// соединение вернется в пул, поэтому подписку нужно снять даже после отмены ctx
+	defer func() {
+		_, _ = conn.Exec(context.WithoutCancel(ctx), "UNLISTEN "+ident)
+	}()
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		handler(notification.Payload)
+	}
+}
+
+// buildUpsertQuery строит INSERT ... ON CONFLICT с плейсхолдерами $1..$n по числу колонок
+func buildUpsertQuery(tableName string, columns []string, target ConflictTarget, updateColumns []string) (string, error) {
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no columns provided for upsert")
+	}
+
+	table, err := sanitizeIdentifier(tableName)
+	if err != nil {
+		return "", err
+	}
+
+	cols, err := sanitizeIdentifiers(columns)
+	if err != nil {
+		return "", err
+	}
+
+	conflict, err := conflictClause(target, updateColumns)
+	if err != nil {
+		return "", err
+	}
+
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) %s",
+		table,
+		cols,
+		strings.Join(placeholders, ","),
+		conflict,
+	)
+
+	return query, nil
+}
+
+// conflictClause строит ON CONFLICT ... DO UPDATE/DO NOTHING
+func conflictClause(conflictTarget ConflictTarget, updateColumns []string) (string, error) {
+	target, err := conflictTarget.clause()
+	if err != nil {
+		return "", err
+	}
+
+	if len(updateColumns) > 0 && target == "" {
+		return "", fmt.Errorf("conflict columns are required for DO UPDATE")
+	}
+
+	if len(updateColumns) == 0 {
+		return fmt.Sprintf("ON CONFLICT%s DO NOTHING", target), nil
+	}
+
+	assignments := make([]string, len(updateColumns))
+	for i, col := range updateColumns {
+		quoted, err := sanitizeIdentifier(col)
+		if err != nil {
+			return "", err
+		}
+		assignments[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoted, quoted)
+	}
+
+	return fmt.Sprintf("ON CONFLICT%s DO UPDATE SET %s", target, strings.Join(assignments, ",")), nil
+}
+
+// maxQueryParams предельное количество параметров ($n) в одном запросе протокола PostgreSQL
+const maxQueryParams = 65535
+
+// buildBulkInsertQuery строит многострочный INSERT с последовательной нумерацией плейсхолдеров.
+// table должна быть уже экранирована
+func buildBulkInsertQuery(table string, columns []string, values [][]any) (string, []any, error) {
+	cols, err := sanitizeIdentifiers(columns)
+	if err != nil {
+		return "", nil, err
+	}
+
+	valueStrings := make([]string, len(values))
+	valueArgs := make([]any, 0, len(values)*len(columns))
+
+	for i, row := range values {
+		placeholders := make([]string, len(row))
+		for j := range row {
+			placeholders[j] = fmt.Sprintf("$%d", len(valueArgs)+j+1)
+		}
+		valueStrings[i] = fmt.Sprintf("(%s)", strings.Join(placeholders, ","))
+		valueArgs = append(valueArgs, row...)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES %s",
+		table,
+		cols,
+		strings.Join(valueStrings, ","),
+	)
+
+	return query, valueArgs, nil
+}
+
+// sanitizeIdentifier проверяет и экранирует идентификатор; имя вида schema.table экранируется по частям.
+// Имена с кавычками, нулевыми байтами или пустыми частями отклоняются
+func sanitizeIdentifier(name string) (string, error) {
+	parts := strings.Split(name, ".")
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, "\"\x00") {
+			return "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+		}
+	}
+
+	return pgx.Identifier(parts).Sanitize(), nil
+}
+
+// sanitizeIdentifiers экранирует список идентификаторов и соединяет их через запятую
+func sanitizeIdentifiers(names []string) (string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		q, err := sanitizeIdentifier(name)
+		if err != nil {
+			return "", err
+		}
+		quoted[i] = q
+	}
+
+	return strings.Join(quoted, ","), nil
+}
+
+var scannerType = reflect.TypeFor[sql.Scanner]()
+
+// rowToAuto выбирает способ сканирования: обычные структуры заполняются по именам колонок,
+// скаляры и типы со своим сканированием (time.Time, pgtype.*) читаются из единственной колонки
+func rowToAuto[T any]() pgx.RowToFunc[T] {
+	if isRowStruct(reflect.TypeFor[T]()) {
+		return pgx.RowToStructByName[T]
+	}
+
+	return pgx.RowTo[T]
+}
+
+// isRowStruct сообщает, что тип сканируется как строка целиком (поля по колонкам), а не как одно значение
+func isRowStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeFor[time.Time]() &&
+		!t.Implements(scannerType) && !reflect.PointerTo(t).Implements(scannerType)
+}
+
+// rowToStructMapped сканирует строку в структуру, сопоставляя колонки с полями по mapping (колонка -> имя поля).
+// Колонки вне mapping ищутся по тегу db и имени поля, как в RowToStructByName; с lax колонки без поля пропускаются.
+// Индексы полей вычисляются по первой строке, поэтому возвращаемая функция предназначена для одного результата
+func rowToStructMapped[T any](mapping map[string]string, lax bool) pgx.RowToFunc[T] {
+	var indexes [][]int
+	return func(row pgx.CollectableRow) (T, error) {
+		var t T
+		rv := reflect.ValueOf(&t).Elem()
+		if rv.Kind() != reflect.Struct {
+			return t, fmt.Errorf("type %T is not a struct", t)
+		}
+
+		if indexes == nil {
+			fields := row.FieldDescriptions()
+			indexes = make([][]int, len(fields))
+			for i, fd := range fields {
+				index, ok := mappedFieldIndex(rv.Type(), mapping, fd.Name)
+				if !ok && !lax {
+					return t, fmt.Errorf("no struct field for column %s", fd.Name)
+				}
+				indexes[i] = index
+			}
+		}
+
+		targets := make([]any, len(indexes))
+		for i, index := range indexes {
+			if index == nil {
+				targets[i] = new(any)
+				continue
+			}
+			targets[i] = rv.FieldByIndex(index).Addr().Interface()
+		}
+
+		return t, row.Scan(targets...)
+	}
+}
+
+// mappedFieldIndex ищет поле для колонки: сначала по mapping, затем по тегу db и имени поля без учета регистра
+// и подчеркиваний, как RowToStructByName
+func mappedFieldIndex(rt reflect.Type, mapping map[string]string, column string) ([]int, bool) {
+	if name, ok := mapping[column]; ok {
+		sf, ok := rt.FieldByName(name)
+		if !ok || !sf.IsExported() {
+			return nil, false
+		}
+		return sf.Index, true
+	}
+
+	for _, sf := range reflect.VisibleFields(rt) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		name, tagged := sf.Tag.Lookup("db")
+		name, _, _ = strings.Cut(name, ",")
+		if name == "-" {
+			continue
+		}
+		if !tagged {
+			name = sf.Name
+		}
+		if strings.EqualFold(strings.ReplaceAll(name, "_", ""), strings.ReplaceAll(column, "_", "")) {
+			return sf.Index, true
+		}
+	}
+
+	return nil, false
+}
+
+// structField колонка и значение поля структуры; omitEmpty - тег с опцией omitempty
+type structField struct {
+	column    string
+	value     any
+	omitEmpty bool
+	zero      bool
+}
+
+// structFields собирает экспортируемые поля структуры по тегам db, разворачивая встроенные структуры без тега
+func structFields(v any) ([]structField, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	return appendStructFields(nil, rv), true
+}
+
+func appendStructFields(fields []structField, rv reflect.Value) []structField {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		tag, hasTag := sf.Tag.Lookup("db")
+		if tag == "-" {
+			continue
+		}
+
+		if sf.Anonymous && !hasTag && sf.Type.Kind() == reflect.Struct {
+			fields = appendStructFields(fields, rv.Field(i))
+			continue
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		column, options, _ := strings.Cut(tag, ",")
+		if column == "" {
+			column = strings.ToLower(sf.Name)
+		}
+
+		fields = append(fields, structField{
+			column:    column,
+			value:     rv.Field(i).Interface(),
+			omitEmpty: options == "omitempty",
+			zero:      rv.Field(i).IsZero(),
+		})
+	}
+
+	return fields
+}
+
+// startQuery проверяет размыкатель пула и начинает span операции, по завершении логирует ошибку, оповещает наблюдателей
+// и хук медленных запросов. Возвращаемую функцию нужно вызвать с итоговой ошибкой запроса и вернуть ее результат:
+// ошибка из-за отмены контекста дополняется именем операции и остается совместимой с errors.Is(err, context.Canceled)
+func startQuery(ctx context.Context, db Querier, op, statement string) (context.Context, func(err error) error, error) {
+	var breaker *circuitBreaker
+	var probe bool
+	if pool, ok := PoolOf(db); ok {
+		if b, ok := breakers.Load(pool); ok {
+			breaker = b.(*circuitBreaker)
+			isProbe, err := breaker.allow()
+			if err != nil {
+				return ctx, nil, err
+			}
+			probe = isProbe
+		}
+	}
+
+	start := time.Now()
+	ctx, endSpan := startSpan(markAcquireStart(ctx), op, statement)
+
+	return ctx, func(err error) error {
+		elapsed := time.Since(start)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = canceledError(op, ctxErr, err)
+				logCanceled(ctx, op, statement, elapsed)
+			} else {
+				logFailure(ctx, op, statement, elapsed, err)
+			}
+		}
+
+		if breaker != nil {
+			breaker.record(err, probe)
+		}
+		notifyObservers(db, op, elapsed, err)
+		notifySlowQuery(statement, elapsed)
+		endSpan(err)
+
+		return err
+	}, nil
+}
+
+// canceledError оборачивает ошибку запроса, прерванного отменой контекста, так что errors.Is
+// находит и ctxErr, и исходную ошибку (например, SQLSTATE 57014 после отмены на сервере)
+func canceledError(op string, ctxErr, err error) error {
+	if errors.Is(err, ctxErr) {
+		return fmt.Errorf("%s canceled: %w", op, err)
+	}
+	return fmt.Errorf("%s canceled: %w: %w", op, ctxErr, err)
+}
+
+// logCanceled пишет в лог запрос, прерванный отменой контекста; это не ошибка базы
+func logCanceled(ctx context.Context, op, statement string, elapsed time.Duration) {
+	if silentEnabled(ctx) {
+		return
+	}
+	format, args := withRequestID(ctx, "%s canceled after %s: %s", []any{op, elapsed, statement})
+	logWarnf(format, args...)
+}
+
+// logFailure пишет в лог ошибку запроса вместе с SQLSTATE, если ее вернул сервер
+func logFailure(ctx context.Context, op, statement string, elapsed time.Duration, err error) {
+	if pgErr, ok := AsPgError(err); ok {
+		format, args := withRequestID(ctx, "%s failed after %s (SQLSTATE %s): %s: %v", []any{op, elapsed, pgErr.Code, statement, err})
+		logErrorf(format, args...)
+		return
+	}
+	format, args := withRequestID(ctx, "%s failed after %s: %s: %v", []any{op, elapsed, statement, err})
+	logErrorf(format, args...)
+}
+
+// isDatabaseFailure сообщает, что ошибка говорит о проблемах самой базы (соединение, ресурсы, остановка),
+// а не о некорректном запросе
+func isDatabaseFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code[:2] {
+		case "08", "53", "57", "58":
+			return true
+		}
+		return false
+	}
+
+	return errors.Is(err, context.DeadlineExceeded) || isConnError(err)
+}
+
+// migration файл миграции с номером версии
+type migration struct {
+	version int64
+	name    string
+	sql     string
+}
+
+// readMigrations читает *.sql файлы каталога и сортирует их по номеру версии из начала имени
+func readMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	seen := make(map[int64]string, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		digits := name[:len(name)-len(strings.TrimLeftFunc(name, unicode.IsDigit))]
+		version, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s must start with a version number", name)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, name)
+		}
+		seen[version] = name
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+
+		migrations = append(migrations, migration{version: version, name: name, sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+
+	return migrations, nil
+}
+
+// pendingMigrations возвращает непримененные миграции, проверяя, что версии файлов идут подряд с 1
+// и история не расходится с файлами
+func pendingMigrations(migrations []migration, applied []int64) ([]migration, error) {
+	for i, m := range migrations {
+		if want := int64(i + 1); m.version != want {
+			return nil, fmt.Errorf("migration %s has version %d, expected %d: versions must be consecutive from 1", m.name, m.version, want)
+		}
+	}
+
+	known := make(map[int64]struct{}, len(migrations))
+	for _, m := range migrations {
+		known[m.version] = struct{}{}
+	}
+
+	done := make(map[int64]struct{}, len(applied))
+	var last int64
+	for _, v := range applied {
+		if _, ok := known[v]; !ok {
+			return nil, fmt.Errorf("applied migration %d is missing", v)
+		}
+		done[v] = struct{}{}
+		last = max(last, v)
+	}
+
+	var pending []migration
+	for _, m := range migrations {
+		if _, ok := done[m.version]; ok {
+			continue
+		}
+		if m.version < last {
+			return nil, fmt.Errorf("migration %s is out of order: version %d is already applied", m.name, last)
+		}
+		pending = append(pending, m)
+	}
+
+	return pending, nil
+}
+
+// applyMigration выполняет миграцию и фиксирует ее версию в одной транзакции
+func applyMigration(ctx context.Context, conn *pgxpool.Conn, m migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if _, err = tx.Exec(ctx, m.sql); err != nil {
+		return fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+	}
+
+	if _, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", m.version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", m.name, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit migration %s: %w", m.name, err)
+	}
+
+	return nil
+}
+
+// advisoryUnlocker возвращает функцию, снимающую блокировку и освобождающую соединение ровно один раз
+func advisoryUnlocker(conn *pgxpool.Conn, key int64) ReleaseFunc {
+	var once sync.Once
+	return func(ctx context.Context) error {
+		var err error
+		once.Do(func() {
+			defer conn.Release()
+			if _, err = conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", key); err != nil {
+				// закрытие сессии снимает блокировку, чтобы она не вернулась в пул вместе с соединением
+				_ = conn.Conn().Close(context.WithoutCancel(ctx))
+				err = fmt.Errorf("failed to release advisory lock %d: %w", key, err)
+			}
+		})
+		return err
+	}
+}
+
+// hasCode сообщает, что в цепочке есть ошибка сервера с указанным SQLSTATE
+func hasCode(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
+
+// buildWith строит секцию WITH из списка CTE
+func buildWith(ctes []CTE, recursive bool) (string, error) {
+	if len(ctes) == 0 {
+		return "", fmt.Errorf("no CTE provided")
+	}
+
+	parts := make([]string, len(ctes))
+	for i, cte := range ctes {
+		if cte.Name == "" || cte.Query == "" {
+			return "", fmt.Errorf("CTE %d must have a name and a query", i)
+		}
+
+		name, err := sanitizeIdentifier(cte.Name)
+		if err != nil {
+			return "", err
+		}
+		if len(cte.Columns) > 0 {
+			cols, err := sanitizeIdentifiers(cte.Columns)
+			if err != nil {
+				return "", err
+			}
+			name += "(" + cols + ")"
+		}
+		parts[i] = fmt.Sprintf("%s AS (%s)", name, cte.Query)
+	}
+
+	with := "WITH "
+	if recursive {
+		with = "WITH RECURSIVE "
+	}
+
+	return with + strings.Join(parts, ", "), nil
+}
+
+// dedupeIDs убирает повторы, сохраняя порядок первых вхождений; несравнимые значения (слайсы, map) дают ошибку
+func dedupeIDs(ids []any) ([]any, error) {
+	seen := make(map[any]struct{}, len(ids))
+	unique := make([]any, 0, len(ids))
+	for i, id := range ids {
+		if id != nil && !reflect.ValueOf(id).Comparable() {
+			return nil, fmt.Errorf("id %d of type %T is not comparable", i, id)
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+
+	return unique, nil
+}
+
+// trimStatement убирает пробелы, завершающие точки с запятой и комментарии после них,
+// чтобы запрос можно было встроить в другой
+func trimStatement(sql string) string {
+	masked := maskSQL(sql)
+	end := len(masked)
+	for end > 0 && strings.IndexByte("; \t\r\n", masked[end-1]) >= 0 {
+		end--
+	}
+
+	return strings.TrimSpace(sql[:end])
+}
+
+// hasTopLevelOrderBy сообщает, что ORDER BY стоит на верхнем уровне запроса,
+// а не в подзапросе, OVER (...), строке или комментарии
+func hasTopLevelOrderBy(sql string) bool {
+	masked := maskSQL(sql)
+	for _, loc := range orderByRe.FindAllStringIndex(masked, -1) {
+		prefix := masked[:loc[0]]
+		if strings.Count(prefix, "(") == strings.Count(prefix, ")") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runInTx выполняет fn и фиксирует tx или откатывает ее при ошибке и панике
+func runInTx(ctx context.Context, tx pgx.Tx, fn func(tx pgx.Tx) error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// structRows раскладывает структуры в колонки и строки значений для вставки.
+// Колонка с omitempty пропускается, если ее поле нулевое во всех структурах, чтобы сработал DEFAULT
+func structRows[T any](values []T) ([]string, [][]any, error) {
+	all := make([][]structField, len(values))
+	for i, v := range values {
+		fields, ok := structFields(v)
+		if !ok {
+			return nil, nil, fmt.Errorf("value of type %T is not a struct", v)
+		}
+		if i > 0 && len(fields) != len(all[0]) {
+			return nil, nil, fmt.Errorf("value %d has %d fields, expected %d", i, len(fields), len(all[0]))
+		}
+		all[i] = fields
+	}
+
+	var keep []int
+	var columns []string
+	for j, f := range all[0] {
+		omit := f.omitEmpty
+		for i := 0; omit && i < len(all); i++ {
+			omit = all[i][j].zero
+		}
+		if !omit {
+			keep = append(keep, j)
+			columns = append(columns, f.column)
+		}
+	}
+
+	rows := make([][]any, len(all))
+	for i, fields := range all {
+		row := make([]any, len(keep))
+		for k, j := range keep {
+			if fields[j].column != columns[k] {
+				return nil, nil, fmt.Errorf("value %d has column %s, expected %s", i, fields[j].column, columns[k])
+			}
+			row[k] = fields[j].value
+		}
+		rows[i] = row
+	}
+
+	return columns, rows, nil
+}
+
+// buildUpdateQuery строит UPDATE ... SET по полям структуры с условием по колонке whereColumn
+func buildUpdateQuery(tableName string, fields []structField, whereColumn string) (string, []any, error) {
+	table, err := sanitizeIdentifier(tableName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	where, err := sanitizeIdentifier(whereColumn)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var key any
+	var found bool
+	var assignments []string
+	args := make([]any, 0, len(fields))
+	for _, f := range fields {
+		if f.column == whereColumn {
+			key, found = f.value, true
+			continue
+		}
+
+		column, err := sanitizeIdentifier(f.column)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, f.value)
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if !found {
+		return "", nil, fmt.Errorf("struct has no field for column %s", whereColumn)
+	}
+	if len(assignments) == 0 {
+		return "", nil, fmt.Errorf("no columns to update")
+	}
+
+	args = append(args, key)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d", table, strings.Join(assignments, ", "), where, len(args))
+
+	return query, args, nil
+}
+
+type acquireStartKey struct{}
+
+// markAcquireStart запоминает в контексте момент, с которого запрос ждет соединение из пула
+func markAcquireStart(ctx context.Context) context.Context {
+	return context.WithValue(ctx, acquireStartKey{}, time.Now())
+}
+
+func acquireStarted(ctx context.Context) (time.Time, bool) {
+	started, ok := ctx.Value(acquireStartKey{}).(time.Time)
+	return started, ok
+}
+
+// splitStatements делит скрипт по точкам с запятой верхнего уровня, пропуская строки ('...', E'...'),
+// идентификаторы в кавычках, комментарии (--, /* */ с вложенностью) и dollar-quoted тела ($$ ... $$, $tag$ ... $tag$)
+func splitStatements(script string) []string {
+	var statements []string
+	begin := 0
+
+	flush := func(end int) {
+		if s := strings.TrimSpace(script[begin:end]); s != "" {
+			statements = append(statements, s)
+		}
+		begin = end + 1
+	}
+
+	masked := maskSQL(script)
+	for i := 0; i < len(masked); i++ {
+		if masked[i] == ';' {
+			flush(i)
+		}
+	}
+	flush(len(script))
+
+	return statements
+}
+
+// maskSQL возвращает копию запроса той же длины, в которой строки, идентификаторы в кавычках и dollar quoting
+// заменены на '_', а комментарии на пробелы; в ней можно искать ключевые слова и скобки без ложных совпадений
+func maskSQL(sql string) string {
+	masked := []byte(sql)
+	fill := func(from, to int, c byte) {
+		for j := from; j < to && j < len(masked); j++ {
+			masked[j] = c
+		}
+	}
+
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'':
+			escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentByte(sql[i-2]))
+			end := skipQuoted(sql, i, '\'', escapes)
+			fill(i, end+1, '_')
+			i = end
+		case c == '"':
+			end := skipQuoted(sql, i, '"', false)
+			fill(i, end+1, '_')
+			i = end
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			start := i
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			fill(start, i, ' ')
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			start := i
+			depth := 1
+			for i += 2; i < len(sql) && depth > 0; i++ {
+				switch {
+				case strings.HasPrefix(sql[i:], "/*"):
+					depth++
+					i++
+				case strings.HasPrefix(sql[i:], "*/"):
+					depth--
+					i++
+				}
+			}
+			fill(start, i, ' ')
+			i--
+		case c == '$' && (i == 0 || !isIdentByte(sql[i-1])):
+			if tag, ok := dollarTag(sql[i:]); ok {
+				end := len(sql)
+				if j := strings.Index(sql[i+len(tag):], tag); j >= 0 {
+					end = i + len(tag) + j + len(tag)
+				}
+				fill(i, end, '_')
+				i = end - 1
+			}
+		}
+	}
+
+	return string(masked)
+}
+
+// skipQuoted возвращает индекс закрывающей кавычки; удвоенная кавычка внутри не закрывает строку
+func skipQuoted(script string, i int, quote byte, backslashEscapes bool) int {
+	for i++; i < len(script); i++ {
+		switch {
+		case backslashEscapes && script[i] == '\\':
+			i++
+		case script[i] == quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(script)
+}
+
+// dollarTag возвращает открывающий тег dollar quoting ($$ или $tag$) в начале s
+func dollarTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		if s[j] == '$' {
+			return s[:j+1], true
+		}
+		if !isIdentByte(s[j]) || (j == 1 && s[j] >= '0' && s[j] <= '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
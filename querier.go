@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	_ Querier = (*pgxpool.Pool)(nil)
+	_ Querier = (*pgxpool.Conn)(nil)
+	_ Querier = (*pgx.Conn)(nil)
+	_ Querier = (pgx.Tx)(nil)
+	_ Querier = (*DB)(nil)
+)
+
+// Querier минимальный набор методов, через который работают хелперы запросов.
+// Его реализуют *pgxpool.Pool, *pgxpool.Conn, *pgx.Conn и pgx.Tx, а в тестах - собственные заглушки.
+// Переданная pgx.Tx делает хелпер частью транзакции, а вложенные транзакции хелперов становятся точками сохранения
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// PoolOf возвращает пул, через который работает db: сам *pgxpool.Pool, текущий пул *DB,
+// а также транзакции и соединения, выданные хелперами библиотеки. Для прочих Querier возвращает false
+func PoolOf(db Querier) (*pgxpool.Pool, bool) {
+	switch q := db.(type) {
+	case *pgxpool.Pool:
+		return q, true
+	case *DB:
+		return q.Pool(), true
+	case *pooledTx:
+		return q.pool, q.pool != nil
+	case *pooledConn:
+		return q.pool, q.pool != nil
+	default:
+		return nil, false
+	}
+}
+
+// pooledTx транзакция, помнящая пул, из которого она открыта; точки сохранения наследуют пул
+type pooledTx struct {
+	pgx.Tx
+	pool *pgxpool.Pool
+}
+
+func (t *pooledTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pooledTx{Tx: tx, pool: t.pool}, nil
+}
+
+// pooledConn соединение, выделенное хелпером из пула
+type pooledConn struct {
+	*pgxpool.Conn
+	pool *pgxpool.Pool
+}
@@ -0,0 +1,270 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeCall запрос, полученный fakeQuerier
+type fakeCall struct {
+	sql  string
+	args []any
+}
+
+// fakeQuerier заглушка Querier для тестов без базы: запоминает запросы, отдает заданные строки, Exec сообщает
+// о affected затронутых строках, а запрос failOn (или любой, если failOn пуст) завершается ошибкой err.
+// Транзакции работают через ту же заглушку и пишут BEGIN, COMMIT и ROLLBACK
+type fakeQuerier struct {
+	mu       sync.Mutex
+	calls    []fakeCall
+	columns  []string
+	rows     [][]any
+	affected int64
+	failOn   string
+	err      error
+}
+
+func (q *fakeQuerier) record(sql string, args []any) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.calls = append(q.calls, fakeCall{sql: sql, args: args})
+	if q.err != nil && (q.failOn == "" || q.failOn == sql) {
+		return q.err
+	}
+	return nil
+}
+
+// statements возвращает тексты полученных запросов по порядку
+func (q *fakeQuerier) statements() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make([]string, len(q.calls))
+	for i, c := range q.calls {
+		out[i] = c.sql
+	}
+	return out
+}
+
+func (q *fakeQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := q.record(sql, args); err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: q.columns, rows: q.rows, pos: -1}, nil
+}
+
+func (q *fakeQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := q.Query(ctx, sql, args...)
+	return &fakeRow{rows: rows, err: err}
+}
+
+func (q *fakeQuerier) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if err := q.record(sql, args); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgconn.NewCommandTag(fmt.Sprintf("UPDATE %d", q.affected)), nil
+}
+
+func (q *fakeQuerier) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := q.record("BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return &fakeTx{q: q}, nil
+}
+
+// fakeTx транзакция fakeQuerier; неиспользуемые методы pgx.Tx не реализованы
+type fakeTx struct {
+	pgx.Tx
+	q *fakeQuerier
+}
+
+func (t *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return t.q.Query(ctx, sql, args...)
+}
+
+func (t *fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return t.q.QueryRow(ctx, sql, args...)
+}
+
+func (t *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return t.q.Exec(ctx, sql, args...)
+}
+
+func (t *fakeTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := t.q.record("SAVEPOINT", nil); err != nil {
+		return nil, err
+	}
+	return &fakeTx{q: t.q}, nil
+}
+
+func (t *fakeTx) Commit(ctx context.Context) error {
+	return t.q.record("COMMIT", nil)
+}
+
+func (t *fakeTx) Rollback(ctx context.Context) error {
+	return t.q.record("ROLLBACK", nil)
+}
+
+func (t *fakeTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	var err error
+	for _, qq := range b.QueuedQueries {
+		if err = t.q.record(qq.SQL, qq.Arguments); err != nil {
+			break
+		}
+	}
+	return &fakeBatchResults{err: err}
+}
+
+// fakeBatchResults результат пакета fakeTx: ошибка первого упавшего запроса
+type fakeBatchResults struct {
+	pgx.BatchResults
+	err error
+}
+
+func (r *fakeBatchResults) Close() error {
+	return r.err
+}
+
+// fakeRows строки fakeQuerier; значения присваиваются в Scan через reflect
+type fakeRows struct {
+	columns []string
+	rows    [][]any
+	pos     int
+	closed  bool
+}
+
+func (r *fakeRows) Close()     { r.closed = true }
+func (r *fakeRows) Err() error { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(fmt.Sprintf("SELECT %d", len(r.rows)))
+}
+func (r *fakeRows) RawValues() [][]byte { return nil }
+func (r *fakeRows) Conn() *pgx.Conn     { return nil }
+
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	fields := make([]pgconn.FieldDescription, len(r.columns))
+	for i, name := range r.columns {
+		fields[i] = pgconn.FieldDescription{Name: name}
+	}
+	return fields
+}
+
+func (r *fakeRows) Next() bool {
+	if r.closed || r.pos+1 >= len(r.rows) {
+		r.closed = true
+		return false
+	}
+	r.pos++
+	return true
+}
+
+func (r *fakeRows) Values() ([]any, error) {
+	return r.rows[r.pos], nil
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	if len(dest) == 1 {
+		if scanner, ok := dest[0].(pgx.RowScanner); ok {
+			return scanner.ScanRow(r)
+		}
+	}
+
+	row := r.rows[r.pos]
+	if len(dest) != len(row) {
+		return fmt.Errorf("scan expects %d destinations, got %d", len(row), len(dest))
+	}
+
+	for i, d := range dest {
+		target := reflect.ValueOf(d).Elem()
+		if row[i] == nil {
+			target.SetZero()
+			continue
+		}
+
+		v := reflect.ValueOf(row[i])
+		if target.Kind() == reflect.Pointer {
+			ptr := reflect.New(target.Type().Elem())
+			ptr.Elem().Set(v.Convert(target.Type().Elem()))
+			target.Set(ptr)
+			continue
+		}
+		target.Set(v.Convert(target.Type()))
+	}
+	return nil
+}
+
+// fakeRow первая строка fakeRows для QueryRow
+type fakeRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		return pgx.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+// userRepo пример вызывающего кода, который зависит от Querier, а не от пула
+type userRepo struct {
+	db Querier
+}
+
+type repoUser struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func (r *userRepo) active(ctx context.Context) ([]repoUser, error) {
+	return QueryStructs[repoUser](ctx, r.db, "SELECT id, name FROM users WHERE active ORDER BY id")
+}
+
+func (r *userRepo) deactivate(ctx context.Context, id int) error {
+	return Exec(ctx, r.db, "UPDATE users SET active = false WHERE id = $1", id)
+}
+
+func TestFakeQuerierDrivesCaller(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"id", "name"}, rows: [][]any{{1, "Ann"}, {2, "Bob"}}}
+	repo := &userRepo{db: db}
+	ctx := context.Background()
+
+	users, err := repo.active(ctx)
+	if err != nil {
+		t.Fatalf("active() error = %v", err)
+	}
+	if want := []repoUser{{1, "Ann"}, {2, "Bob"}}; !reflect.DeepEqual(users, want) {
+		t.Fatalf("active() = %v, want %v", users, want)
+	}
+
+	if err = repo.deactivate(ctx, 2); err != nil {
+		t.Fatalf("deactivate() error = %v", err)
+	}
+	if got := db.calls[len(db.calls)-1]; got.sql != "UPDATE users SET active = false WHERE id = $1" || !reflect.DeepEqual(got.args, []any{2}) {
+		t.Fatalf("deactivate() executed %q with %v", got.sql, got.args)
+	}
+}
+
+func TestFakeQuerierPropagatesErrors(t *testing.T) {
+	errBroken := errors.New("broken")
+	repo := &userRepo{db: &fakeQuerier{err: errBroken}}
+
+	if _, err := repo.active(context.Background()); !errors.Is(err, errBroken) {
+		t.Fatalf("active() error = %v, want %v", err, errBroken)
+	}
+	if err := repo.deactivate(context.Background(), 1); !errors.Is(err, errBroken) {
+		t.Fatalf("deactivate() error = %v, want %v", err, errBroken)
+	}
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"gitlab.com/nevasik7/lg"
+	"time"
+)
+
+type retryWritesKey struct{}
+
+type txConflictRetriesKey struct{}
+
+// txConflictBackoff базовая пауза перед повтором транзакции, растет линейно с номером попытки
+const txConflictBackoff = 50 * time.Millisecond
+
+// WithRetryWrites разрешает повтор изменяющего запроса (Exec, ExecReturning, BulkInsert и т.п.) при обрыве соединения
+// после его отправки (EOF, conn closed). Используйте только для идемпотентных изменений: при обрыве запрос мог успеть
+// выполниться. Чтения повторяются и без этой опции, как и запрос, который не ушел на сервер
+func WithRetryWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryWritesKey{}, true)
+}
+
+func retryWritesEnabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(retryWritesKey{}).(bool)
+	return enabled
+}
+
+// WithTxConflictRetries разрешает до retries повторов транзакции RequestInOneTransaction
+// (и RequestInOneTransactionBatch) при deadlock или ошибке сериализации. По умолчанию повторов нет
+func WithTxConflictRetries(ctx context.Context, retries int) context.Context {
+	return context.WithValue(ctx, txConflictRetriesKey{}, retries)
+}
+
+// retryOnTxConflict выполняет fn и повторяет ее с паузой, пока она завершается конфликтом транзакций
+// и не исчерпаны повторы, заданные через WithTxConflictRetries
+func retryOnTxConflict(ctx context.Context, fn func() error) error {
+	retries, _ := ctx.Value(txConflictRetriesKey{}).(int)
+
+	err := fn()
+	for attempt := 1; attempt <= retries && isTxConflict(err); attempt++ {
+		lg.Infof("Retrying transaction after conflict (attempt %d of %d): %v", attempt, retries, err)
+
+		timer := time.NewTimer(time.Duration(attempt) * txConflictBackoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		err = fn()
+	}
+
+	return err
+}
+
+// isTxConflict сообщает, что транзакция прервана из-за конкурентной транзакции и ее можно повторить
+func isTxConflict(err error) bool {
+	return hasCode(err, CodeDeadlockDetected) || hasCode(err, CodeSerializationFailure)
+}
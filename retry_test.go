@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// unsentError ошибка запроса, который не успел уйти на сервер
+type unsentError struct{}
+
+func (unsentError) Error() string     { return "dial failed" }
+func (unsentError) SafeToRetry() bool { return true }
+
+func TestRetryOnConnError(t *testing.T) {
+	pool := (*pgxpool.Pool)(nil)
+	tx := &pooledTx{}
+
+	tests := []struct {
+		name  string
+		db    Querier
+		err   error
+		calls int
+	}{
+		{"success", pool, nil, 1},
+		{"unsent query on pool", pool, unsentError{}, 2},
+		{"unsent query on db", &DB{}, unsentError{}, 2},
+		{"eof after send", pool, io.ErrUnexpectedEOF, 2},
+		{"conn closed", &DB{}, errors.New("conn closed"), 2},
+		{"conn busy", pool, errors.New("conn busy"), 2},
+		{"server error", pool, &pgconn.PgError{Code: "23505"}, 1},
+		{"canceled", pool, context.Canceled, 1},
+		{"unsent query in tx", tx, unsentError{}, 1},
+		{"eof in tx", tx, io.EOF, 1},
+		{"unsent query on conn", &pooledConn{}, unsentError{}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := retryOnConnError(context.Background(), tt.db, func() (int, error) {
+				calls++
+				return 0, tt.err
+			})
+
+			if calls != tt.calls {
+				t.Fatalf("fn called %d times, want %d", calls, tt.calls)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("retryOnConnError() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRetryWriteOnConnError(t *testing.T) {
+	pool := (*pgxpool.Pool)(nil)
+	tx := &pooledTx{}
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		db    Querier
+		err   error
+		calls int
+	}{
+		{"success", context.Background(), pool, nil, 1},
+		{"unsent query on pool", context.Background(), pool, unsentError{}, 2},
+		{"unsent query on db", context.Background(), &DB{}, unsentError{}, 2},
+		{"eof without retry writes", context.Background(), pool, io.ErrUnexpectedEOF, 1},
+		{"eof with retry writes", WithRetryWrites(context.Background()), pool, io.ErrUnexpectedEOF, 2},
+		{"conn closed with retry writes", WithRetryWrites(context.Background()), pool, errors.New("conn closed"), 2},
+		{"server error", WithRetryWrites(context.Background()), pool, &pgconn.PgError{Code: "23505"}, 1},
+		{"unsent query in tx", context.Background(), tx, unsentError{}, 1},
+		{"eof in tx with retry writes", WithRetryWrites(context.Background()), tx, io.EOF, 1},
+		{"unsent query on conn", context.Background(), &pooledConn{}, unsentError{}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, err := retryWriteOnConnError(tt.ctx, tt.db, func() (int, error) {
+				calls++
+				return 0, tt.err
+			})
+
+			if calls != tt.calls {
+				t.Fatalf("fn called %d times, want %d", calls, tt.calls)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("retryWriteOnConnError() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRetryOnConnErrorReturnsRetryResult(t *testing.T) {
+	calls := 0
+	got, err := retryOnConnError(context.Background(), (*pgxpool.Pool)(nil), func() (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 42, nil
+	})
+
+	if err != nil || got != 42 {
+		t.Fatalf("retryOnConnError() = %d, %v, want 42, nil", got, err)
+	}
+}
+
+func TestRetryOnTxConflict(t *testing.T) {
+	deadlock := &pgconn.PgError{Code: CodeDeadlockDetected}
+
+	tests := []struct {
+		name    string
+		retries int
+		errs    []error
+		calls   int
+		wantErr bool
+	}{
+		{"no retries by default", 0, []error{deadlock, nil}, 1, true},
+		{"retried deadlock succeeds", 2, []error{deadlock, &pgconn.PgError{Code: CodeSerializationFailure}, nil}, 3, false},
+		{"retries exhausted", 1, []error{deadlock, deadlock, nil}, 2, true},
+		{"other errors are not retried", 3, []error{errors.New("syntax error"), nil}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.retries > 0 {
+				ctx = WithTxConflictRetries(ctx, tt.retries)
+			}
+
+			calls := 0
+			err := retryOnTxConflict(ctx, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+
+			if calls != tt.calls {
+				t.Fatalf("calls = %d, want %d", calls, tt.calls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retryOnTxConflict() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryOnTxConflictStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithTxConflictRetries(context.Background(), 5))
+	deadlock := &pgconn.PgError{Code: CodeDeadlockDetected}
+
+	calls := 0
+	err := retryOnTxConflict(ctx, func() error {
+		calls++
+		cancel()
+		return deadlock
+	})
+
+	if calls != 1 || !errors.Is(err, deadlock) {
+		t.Fatalf("retryOnTxConflict() = %v after %d calls, want the deadlock after 1 call", err, calls)
+	}
+}
+
+func TestRequestInOneTransactionRetriesDeadlock(t *testing.T) {
+	pool := testPool(t)
+	ctx := WithTxConflictRetries(context.Background(), 3)
+	table := testTable(t, pool, "id int PRIMARY KEY, n int NOT NULL")
+	mustExec(t, pool, "INSERT INTO "+table+" VALUES (1, 0), (2, 0)")
+
+	// встречный порядок блокировок с паузой между ними гарантирует deadlock одной из транзакций
+	lockInOrder := func(first, second int) string {
+		return fmt.Sprintf("UPDATE %[1]s SET n = n + 1 WHERE id = %[2]d; SELECT pg_sleep(0.2); UPDATE %[1]s SET n = n + 1 WHERE id = %[3]d",
+			table, first, second)
+	}
+
+	errs := make(chan error, 2)
+	for _, order := range [][2]int{{1, 2}, {2, 1}} {
+		go func() {
+			errs <- RequestInOneTransaction(ctx, pool, map[string][]any{lockInOrder(order[0], order[1]): nil})
+		}()
+	}
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatalf("RequestInOneTransaction() error = %v", err)
+		}
+	}
+
+	counts, err := QuerySimple[int](context.Background(), pool, "SELECT n FROM "+table+" ORDER BY id")
+	if err != nil {
+		t.Fatalf("QuerySimple() error = %v", err)
+	}
+	if want := []int{2, 2}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("counters = %v, want %v: both transactions must commit exactly once", counts, want)
+	}
+}
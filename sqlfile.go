@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"os"
+	"time"
+)
+
+// ExecSQL разбивает скрипт на отдельные запросы и выполняет их по порядку в одной транзакции.
+// Точка с запятой внутри строк, идентификаторов в кавычках, комментариев и тел $$ ... $$ не считается границей.
+// Команды, которые нельзя выполнять в транзакции (CREATE INDEX CONCURRENTLY, VACUUM), не поддерживаются
+func ExecSQL(ctx context.Context, db Querier, script string) (err error) {
+	ctx, finish, err := startQuery(ctx, db, "ExecSQL", "BEGIN")
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed sql script in %s", elapsed)
+	}()
+
+	statements := splitStatements(script)
+
+	tx, err := beginTransaction(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return runInTx(ctx, tx, func(tx pgx.Tx) error {
+		for i, statement := range statements {
+			if _, err := tx.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("failed to execute statement %d: %w", i+1, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ExecFile читает SQL-файл и выполняет его через ExecSQL
+func ExecFile(ctx context.Context, db Querier, path string) error {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read sql file: %w", err)
+	}
+
+	if err = ExecSQL(ctx, db, string(script)); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"single without semicolon", "SELECT 1", []string{"SELECT 1"}},
+		{"several with blanks", "SELECT 1;\n\n;SELECT 2;  \n", []string{"SELECT 1", "SELECT 2"}},
+		{"string literal", "INSERT INTO t VALUES ('a;b'); SELECT 'it''s;'", []string{"INSERT INTO t VALUES ('a;b')", "SELECT 'it''s;'"}},
+		{"escape string", `SELECT E'a\';b'; SELECT 2`, []string{`SELECT E'a\';b'`, "SELECT 2"}},
+		{"quoted identifier", `SELECT 1 AS "a;b"; SELECT 2`, []string{`SELECT 1 AS "a;b"`, "SELECT 2"}},
+		{"line comment", "SELECT 1; -- comment; not a statement\nSELECT 2", []string{"SELECT 1", "-- comment; not a statement\nSELECT 2"}},
+		{"nested block comment", "SELECT 1 /* a; /* b; */ c; */; SELECT 2", []string{"SELECT 1 /* a; /* b; */ c; */", "SELECT 2"}},
+		{
+			name: "dollar-quoted function body",
+			script: `CREATE FUNCTION inc(i int) RETURNS int AS $$
+BEGIN
+	RETURN i + 1;
+END;
+$$ LANGUAGE plpgsql;
+SELECT inc(1);`,
+			want: []string{"CREATE FUNCTION inc(i int) RETURNS int AS $$\nBEGIN\n\tRETURN i + 1;\nEND;\n$$ LANGUAGE plpgsql", "SELECT inc(1)"},
+		},
+		{"tagged dollar quote", "SELECT $fn$ a; $$ b; $fn$; SELECT 2", []string{"SELECT $fn$ a; $$ b; $fn$", "SELECT 2"}},
+		{"positional parameter", "SELECT $1; SELECT 2", []string{"SELECT $1", "SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitStatements() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExecSQLRunsStatementsInTransaction(t *testing.T) {
+	db := &fakeQuerier{}
+
+	if err := ExecSQL(context.Background(), db, "CREATE TABLE a (id int);\nINSERT INTO a VALUES (1);"); err != nil {
+		t.Fatalf("ExecSQL() error = %v", err)
+	}
+
+	want := []string{"BEGIN", "CREATE TABLE a (id int)", "INSERT INTO a VALUES (1)", "COMMIT"}
+	if got := db.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestExecSQLRollsBackOnError(t *testing.T) {
+	db := &fakeQuerier{failOn: "INSERT INTO a VALUES (1)", err: errors.New("broken")}
+
+	if err := ExecSQL(context.Background(), db, "CREATE TABLE a (id int); INSERT INTO a VALUES (1); SELECT 1"); err == nil {
+		t.Fatal("ExecSQL() error = nil")
+	}
+
+	want := []string{"BEGIN", "CREATE TABLE a (id int)", "INSERT INTO a VALUES (1)", "ROLLBACK"}
+	if got := db.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestExecFileMissing(t *testing.T) {
+	if err := ExecFile(context.Background(), &fakeQuerier{}, filepath.Join(t.TempDir(), "missing.sql")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("ExecFile() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestExecFile(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int")
+
+	fn := table + "_inc"
+	t.Cleanup(func() {
+		_, _ = pool.Exec(context.Background(), "DROP FUNCTION IF EXISTS "+fn+"(int)")
+	})
+
+	script := `CREATE FUNCTION ` + fn + `(i int) RETURNS int AS $$
+BEGIN
+	-- точка с запятой внутри тела функции не разделяет запросы;
+	RETURN i + 1;
+END;
+$$ LANGUAGE plpgsql;
+
+INSERT INTO ` + table + ` VALUES (` + fn + `(1)), (` + fn + `(2));
+`
+	path := filepath.Join(t.TempDir(), "bootstrap.sql")
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatalf("failed to write sql file: %v", err)
+	}
+
+	if err := ExecFile(ctx, pool, path); err != nil {
+		t.Fatalf("ExecFile() error = %v", err)
+	}
+
+	ids, err := QuerySimple[int](ctx, pool, "SELECT id FROM "+table+" ORDER BY id")
+	if err != nil {
+		t.Fatalf("QuerySimple() error = %v", err)
+	}
+	if want := []int{2, 3}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+}
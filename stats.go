@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+// Stats снимок состояния пула подключений, не зависящий от типов pgx
+type Stats struct {
+	AcquiredConns           int32
+	IdleConns               int32
+	TotalConns              int32
+	ConstructingConns       int32
+	MaxConns                int32
+	AcquireCount            int64
+	AcquireDuration         time.Duration
+	CanceledAcquireCount    int64
+	EmptyAcquireCount       int64
+	NewConnsCount           int64
+	MaxLifetimeDestroyCount int64
+	MaxIdleDestroyCount     int64
+}
+
+// PoolStats возвращает статистику использования пула подключений
+func PoolStats(pool *pgxpool.Pool) Stats {
+	s := pool.Stat()
+
+	return Stats{
+		AcquiredConns:           s.AcquiredConns(),
+		IdleConns:               s.IdleConns(),
+		TotalConns:              s.TotalConns(),
+		ConstructingConns:       s.ConstructingConns(),
+		MaxConns:                s.MaxConns(),
+		AcquireCount:            s.AcquireCount(),
+		AcquireDuration:         s.AcquireDuration(),
+		CanceledAcquireCount:    s.CanceledAcquireCount(),
+		EmptyAcquireCount:       s.EmptyAcquireCount(),
+		NewConnsCount:           s.NewConnsCount(),
+		MaxLifetimeDestroyCount: s.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     s.MaxIdleDestroyCount(),
+	}
+}
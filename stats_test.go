@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPoolStatsFreshPool(t *testing.T) {
+	pool, err := NewDB(context.Background(), &DBConfig{Host: "localhost", Db: "test"}, WithMaxConns(7))
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer pool.Close()
+
+	s := PoolStats(pool)
+	if s.MaxConns != 7 {
+		t.Fatalf("MaxConns = %d, want 7", s.MaxConns)
+	}
+	if s.TotalConns != 0 || s.AcquiredConns != 0 || s.AcquireCount != 0 {
+		t.Fatalf("PoolStats() = %+v, want no connections in a fresh pool", s)
+	}
+}
+
+func TestPoolStatsCountsAcquires(t *testing.T) {
+	pool := testPool(t)
+
+	conn, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	s := PoolStats(pool)
+	if s.AcquiredConns != 1 || s.AcquireCount < 1 || s.TotalConns < 1 {
+		t.Fatalf("PoolStats() = %+v with one acquired connection", s)
+	}
+
+	conn.Release()
+	if s = PoolStats(pool); s.AcquiredConns != 0 || s.IdleConns < 1 {
+		t.Fatalf("PoolStats() = %+v after release, want the connection idle", s)
+	}
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"iter"
+	"time"
+)
+
+// chunkCursorName имя серверного курсора QueryStructsChunked; курсор живет только в своей транзакции
+const chunkCursorName = "postgres_lib_chunk_cursor"
+
+// QueryStream выполняет запрос и вызывает fn для каждой строки, не загружая весь результат в память.
+// Итерация прерывается на первой ошибке fn, и эта ошибка возвращается
+func QueryStream[T any](ctx context.Context, db Querier, sql string, fn func(T) error, args ...any) (err error) {
+	ctx, finish, err := startQuery(ctx, db, "QueryStream", sql)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed %s in %s", sql, elapsed)
+	}()
+
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	scan := rowToAuto[T]()
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return err
+		}
+
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// QueryIter возвращает итератор по строкам запроса для range-over-func.
+// Запрос выполняется при начале итерации, выход из цикла закрывает rows и возвращает соединение в пул
+func QueryIter[T any](ctx context.Context, db Querier, sql string, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		if err := queryIter(ctx, db, sql, yield, args...); err != nil {
+			yield(*new(T), err)
+		}
+	}
+}
+
+// queryIter выполняет запрос QueryIter и передает строки в yield; nil возвращается и при выходе из цикла
+func queryIter[T any](ctx context.Context, db Querier, sql string, yield func(T, error) bool, args ...any) (err error) {
+	ctx, finish, err := startQuery(ctx, db, "QueryIter", sql)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed %s in %s", sql, elapsed)
+	}()
+
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	scan := rowToAuto[T]()
+	for rows.Next() {
+		v, err := scan(rows)
+		if err != nil {
+			return err
+		}
+
+		if !yield(v, nil) {
+			return nil
+		}
+	}
+
+	return rows.Err()
+}
+
+// QueryStructsChunked открывает серверный курсор в транзакции и читает результат частями по chunkSize строк,
+// вызывая fn для каждой части. В памяти одновременно находится не больше одной части
+func QueryStructsChunked[T any](ctx context.Context, db Querier, sql string, chunkSize int, fn func([]T) error, args ...any) (err error) {
+	if chunkSize <= 0 {
+		return fmt.Errorf("chunk size must be positive, got %d", chunkSize)
+	}
+
+	ctx, finish, err := startQuery(ctx, db, "QueryStructsChunked", sql)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed chunked %s in %s", sql, elapsed)
+	}()
+
+	tx, err := beginTransaction(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return runInTx(ctx, tx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "DECLARE "+chunkCursorName+" NO SCROLL CURSOR FOR "+trimStatement(sql), args...); err != nil {
+			return fmt.Errorf("failed to declare cursor: %w", err)
+		}
+
+		fetch := fmt.Sprintf("FETCH %d FROM %s", chunkSize, chunkCursorName)
+		for {
+			rows, err := tx.Query(ctx, fetch)
+			if err != nil {
+				return fmt.Errorf("failed to fetch from cursor: %w", err)
+			}
+
+			chunk, err := pgx.CollectRows(rows, pgx.RowToStructByName[T])
+			if err != nil {
+				return err
+			}
+
+			if len(chunk) == 0 {
+				return nil
+			}
+
+			if err = fn(chunk); err != nil {
+				return err
+			}
+
+			if len(chunk) < chunkSize {
+				return nil
+			}
+		}
+	})
+}
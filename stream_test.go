@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type streamItem struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+func streamQuerier() *fakeQuerier {
+	return &fakeQuerier{
+		columns: []string{"id", "name"},
+		rows:    [][]any{{1, "a"}, {2, "b"}, {3, "c"}},
+	}
+}
+
+func TestQueryStream(t *testing.T) {
+	var got []streamItem
+	err := QueryStream(context.Background(), streamQuerier(), "SELECT id, name FROM items", func(item streamItem) error {
+		got = append(got, item)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("QueryStream() error = %v", err)
+	}
+
+	if want := []streamItem{{1, "a"}, {2, "b"}, {3, "c"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryStream() streamed %v, want %v", got, want)
+	}
+}
+
+func TestQueryStreamStopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+
+	calls := 0
+	err := QueryStream(context.Background(), streamQuerier(), "SELECT id, name FROM items", func(item streamItem) error {
+		calls++
+		if item.ID == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	if !errors.Is(err, stop) {
+		t.Fatalf("QueryStream() error = %v, want %v", err, stop)
+	}
+	if calls != 2 {
+		t.Fatalf("callback called %d times, want 2", calls)
+	}
+}
+
+func TestQueryStreamQueryError(t *testing.T) {
+	queryErr := errors.New("relation does not exist")
+	db := &fakeQuerier{err: queryErr}
+
+	err := QueryStream(context.Background(), db, "SELECT 1", func(int) error { return nil })
+	if !errors.Is(err, queryErr) {
+		t.Fatalf("QueryStream() error = %v, want %v", err, queryErr)
+	}
+}
+
+func TestQueryIter(t *testing.T) {
+	var got []streamItem
+	for item, err := range QueryIter[streamItem](context.Background(), streamQuerier(), "SELECT id, name FROM items") {
+		if err != nil {
+			t.Fatalf("QueryIter() error = %v", err)
+		}
+		got = append(got, item)
+	}
+
+	if want := []streamItem{{1, "a"}, {2, "b"}, {3, "c"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryIter() yielded %v, want %v", got, want)
+	}
+}
+
+func TestQueryIterBreak(t *testing.T) {
+	var got []int
+	for item, err := range QueryIter[streamItem](context.Background(), streamQuerier(), "SELECT id, name FROM items") {
+		if err != nil {
+			t.Fatalf("QueryIter() error = %v", err)
+		}
+		got = append(got, item.ID)
+		if item.ID == 2 {
+			break
+		}
+	}
+
+	if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryIter() yielded %v, want %v", got, want)
+	}
+}
+
+func TestQueryIterQueryError(t *testing.T) {
+	queryErr := errors.New("relation does not exist")
+
+	yields := 0
+	for _, err := range QueryIter[int](context.Background(), &fakeQuerier{err: queryErr}, "SELECT 1") {
+		yields++
+		if !errors.Is(err, queryErr) {
+			t.Fatalf("QueryIter() error = %v, want %v", err, queryErr)
+		}
+	}
+
+	if yields != 1 {
+		t.Fatalf("QueryIter() yielded %d times, want 1", yields)
+	}
+}
+
+func TestQueryIterNotifiesObservers(t *testing.T) {
+	db := streamQuerier()
+
+	var ops []string
+	remove := AddQueryObserver(func(q Querier, op string, duration time.Duration, err error) {
+		if q == Querier(db) {
+			ops = append(ops, op)
+		}
+	})
+	defer remove()
+
+	for range QueryIter[streamItem](context.Background(), db, "SELECT id, name FROM items") {
+		break
+	}
+
+	if want := []string{"QueryIter"}; !reflect.DeepEqual(ops, want) {
+		t.Fatalf("observed operations %v, want %v", ops, want)
+	}
+}
+
+func TestQueryStructsChunkedRejectsChunkSize(t *testing.T) {
+	db := &fakeQuerier{}
+
+	err := QueryStructsChunked(context.Background(), db, "SELECT id, name FROM items", 0, func([]streamItem) error { return nil })
+	if err == nil {
+		t.Fatal("QueryStructsChunked() error = nil for zero chunk size")
+	}
+	if got := db.statements(); len(got) != 0 {
+		t.Fatalf("QueryStructsChunked() executed %q", got)
+	}
+}
+
+func TestQueryStructsChunked(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY, name text NOT NULL")
+	mustExec(t, pool, "INSERT INTO "+table+" SELECT g, 'name' || g FROM generate_series(1, 10) g")
+
+	var sizes, ids []int
+	err := QueryStructsChunked(ctx, pool, "SELECT id, name FROM "+table+" WHERE id > $1 ORDER BY id", 3, func(chunk []streamItem) error {
+		sizes = append(sizes, len(chunk))
+		for _, item := range chunk {
+			ids = append(ids, item.ID)
+		}
+		return nil
+	}, 0)
+	if err != nil {
+		t.Fatalf("QueryStructsChunked() error = %v", err)
+	}
+
+	if want := []int{3, 3, 3, 1}; !reflect.DeepEqual(sizes, want) {
+		t.Fatalf("chunk sizes = %v, want %v", sizes, want)
+	}
+	if want := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestQueryStructsChunkedStopsOnCallbackError(t *testing.T) {
+	pool := testPool(t)
+	table := testTable(t, pool, "id int PRIMARY KEY, name text NOT NULL")
+	mustExec(t, pool, "INSERT INTO "+table+" SELECT g, 'name' || g FROM generate_series(1, 10) g")
+	stop := errors.New("stop")
+
+	calls := 0
+	err := QueryStructsChunked(context.Background(), pool, "SELECT id, name FROM "+table, 4, func([]streamItem) error {
+		calls++
+		return stop
+	})
+
+	if !errors.Is(err, stop) {
+		t.Fatalf("QueryStructsChunked() error = %v, want %v", err, stop)
+	}
+	if calls != 1 {
+		t.Fatalf("callback called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+// StructToNamedArgs превращает поля структуры в именованные аргументы для запросов вида @field.
+// Имена берутся из тега db (как в RowToStructByName), поля с тегом "-" пропускаются.
+// Для значения, не являющегося структурой или указателем на нее, возвращается nil
+func StructToNamedArgs(v any) pgx.NamedArgs {
+	fields, ok := structFields(v)
+	if !ok {
+		return nil
+	}
+
+	args := make(pgx.NamedArgs, len(fields))
+	for _, f := range fields {
+		args[f.column] = f.value
+	}
+
+	return args
+}
+
+// InsertStruct вставляет структуру в таблицу, беря колонки из тегов db. Поля с тегом "-" пропускаются,
+// а нулевые поля с опцией omitempty (например, `db:"id,omitempty"` для serial) не передаются, чтобы сработал DEFAULT
+func InsertStruct(ctx context.Context, db Querier, tableName string, v any) error {
+	return InsertStructs(ctx, db, tableName, []any{v})
+}
+
+// InsertStructs вставляет слайс структур одним запросом через BulkInsert.
+// Колонка с omitempty пропускается, только если поле нулевое во всех структурах
+func InsertStructs[T any](ctx context.Context, db Querier, tableName string, values []T) error {
+	if len(values) == 0 {
+		return fmt.Errorf("no values provided for insert")
+	}
+
+	columns, rows, err := structRows(values)
+	if err != nil {
+		return err
+	}
+
+	return BulkInsert(ctx, db, tableName, columns, rows)
+}
+
+// UpdateStruct обновляет строки, у которых whereColumn равна значению соответствующего поля v,
+// записывая остальные поля структуры (колонка ключа в SET не попадает). Возвращает количество обновленных строк
+func UpdateStruct(ctx context.Context, db Querier, tableName string, v any, whereColumn string) (_ int64, err error) {
+	ctx, finish, err := startQuery(ctx, db, "UpdateStruct", "UPDATE "+tableName)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed update of the %s in %s", tableName, elapsed)
+	}()
+
+	fields, ok := structFields(v)
+	if !ok {
+		return 0, fmt.Errorf("value of type %T is not a struct", v)
+	}
+
+	query, args, err := buildUpdateQuery(tableName, fields, whereColumn)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := db.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("update failed: %w", err)
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// BulkUpsertStructs вставляет слайс структур через INSERT ... ON CONFLICT, беря колонки из тегов db, и возвращает
+// количество вставленных и обновленных строк. Строки отправляются частями, чтобы не превысить лимит параметров
+// PostgreSQL, все части выполняются в одной транзакции. Одна строка не может встречаться в rows дважды
+func BulkUpsertStructs[T any](ctx context.Context, db Querier, tableName string, rows []T, conflictColumns, updateColumns []string) (int64, error) {
+	if len(rows) == 0 {
+		return 0, fmt.Errorf("no values provided for upsert")
+	}
+
+	table, err := sanitizeIdentifier(tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	conflict, err := conflictClause(ConflictTarget{Columns: conflictColumns}, updateColumns)
+	if err != nil {
+		return 0, err
+	}
+
+	columns, values, err := structRows(rows)
+	if err != nil {
+		return 0, err
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("no columns to insert")
+	}
+
+	chunkSize := maxQueryParams / len(columns)
+
+	var total int64
+	err = WithTransaction(ctx, db, func(tx pgx.Tx) error {
+		for from := 0; from < len(values); from += chunkSize {
+			to := min(from+chunkSize, len(values))
+
+			query, args, err := buildBulkInsertQuery(table, columns, values[from:to])
+			if err != nil {
+				return err
+			}
+
+			tag, err := tx.Exec(ctx, query+" "+conflict, args...)
+			if err != nil {
+				return fmt.Errorf("bulk upsert of rows %d-%d failed: %w", from, to, err)
+			}
+			total += tag.RowsAffected()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
@@ -0,0 +1,270 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"reflect"
+	"testing"
+)
+
+type auditFields struct {
+	CreatedBy string `db:"created_by"`
+}
+
+type namedArgsUser struct {
+	auditFields
+	ID       int    `db:"id"`
+	Name     string `db:"name,omitempty"`
+	Email    string
+	Password string `db:"-"`
+	internal int
+}
+
+func TestStructToNamedArgs(t *testing.T) {
+	user := namedArgsUser{
+		auditFields: auditFields{CreatedBy: "admin"},
+		ID:          7,
+		Name:        "Ann",
+		Email:       "ann@example.com",
+		Password:    "secret",
+		internal:    1,
+	}
+
+	want := pgx.NamedArgs{
+		"created_by": "admin",
+		"id":         7,
+		"name":       "Ann",
+		"email":      "ann@example.com",
+	}
+
+	if got := StructToNamedArgs(user); !reflect.DeepEqual(got, want) {
+		t.Fatalf("StructToNamedArgs() = %v, want %v", got, want)
+	}
+	if got := StructToNamedArgs(&user); !reflect.DeepEqual(got, want) {
+		t.Fatalf("StructToNamedArgs(pointer) = %v, want %v", got, want)
+	}
+}
+
+func TestStructToNamedArgsNotStruct(t *testing.T) {
+	var nilUser *namedArgsUser
+	for _, v := range []any{42, "text", nil, nilUser} {
+		if got := StructToNamedArgs(v); got != nil {
+			t.Errorf("StructToNamedArgs(%#v) = %v, want nil", v, got)
+		}
+	}
+}
+
+type insertItem struct {
+	ID    int    `db:"id,omitempty"`
+	Name  string `db:"name"`
+	Notes string `db:"-"`
+}
+
+func TestStructRowsOmitEmpty(t *testing.T) {
+	columns, rows, err := structRows([]insertItem{{Name: "a", Notes: "x"}, {Name: "b"}})
+	if err != nil {
+		t.Fatalf("structRows() error = %v", err)
+	}
+	if want := []string{"name"}; !reflect.DeepEqual(columns, want) {
+		t.Fatalf("structRows() columns = %v, want %v", columns, want)
+	}
+	if want := [][]any{{"a"}, {"b"}}; !reflect.DeepEqual(rows, want) {
+		t.Fatalf("structRows() rows = %v, want %v", rows, want)
+	}
+
+	columns, rows, err = structRows([]insertItem{{Name: "a"}, {ID: 5, Name: "b"}})
+	if err != nil {
+		t.Fatalf("structRows() error = %v", err)
+	}
+	if want := []string{"id", "name"}; !reflect.DeepEqual(columns, want) {
+		t.Fatalf("structRows() columns = %v, want %v when one id is set", columns, want)
+	}
+	if want := [][]any{{0, "a"}, {5, "b"}}; !reflect.DeepEqual(rows, want) {
+		t.Fatalf("structRows() rows = %v, want %v", rows, want)
+	}
+}
+
+func TestInsertStructs(t *testing.T) {
+	ctx := context.Background()
+
+	db := &fakeQuerier{}
+	if err := InsertStruct(ctx, db, "items", &insertItem{Name: "a"}); err != nil {
+		t.Fatalf("InsertStruct() error = %v", err)
+	}
+	if err := InsertStructs(ctx, db, "items", []insertItem{{ID: 1, Name: "b"}, {ID: 2, Name: "c"}}); err != nil {
+		t.Fatalf("InsertStructs() error = %v", err)
+	}
+
+	want := []fakeCall{
+		{sql: `INSERT INTO "items" ("name") VALUES ($1)`, args: []any{"a"}},
+		{sql: `INSERT INTO "items" ("id","name") VALUES ($1,$2),($3,$4)`, args: []any{1, "b", 2, "c"}},
+	}
+	if !reflect.DeepEqual(db.calls, want) {
+		t.Fatalf("calls = %v, want %v", db.calls, want)
+	}
+}
+
+func TestInsertStructsErrors(t *testing.T) {
+	ctx := context.Background()
+
+	if err := InsertStructs[insertItem](ctx, &fakeQuerier{}, "items", nil); err == nil {
+		t.Error("InsertStructs() error = nil for no values")
+	}
+	if err := InsertStruct(ctx, &fakeQuerier{}, "items", 42); err == nil {
+		t.Error("InsertStruct() error = nil for a non-struct value")
+	}
+}
+
+func TestInsertStructStoresValues(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id serial PRIMARY KEY, name text NOT NULL")
+
+	if err := InsertStruct(ctx, pool, table, insertItem{Name: "first"}); err != nil {
+		t.Fatalf("InsertStruct() error = %v", err)
+	}
+	if err := InsertStructs(ctx, pool, table, []insertItem{{Name: "second"}, {Name: "third"}}); err != nil {
+		t.Fatalf("InsertStructs() error = %v", err)
+	}
+
+	got, err := QueryStructs[insertItem](ctx, pool, "SELECT id, name FROM "+table+" ORDER BY id")
+	if err != nil {
+		t.Fatalf("QueryStructs() error = %v", err)
+	}
+	want := []insertItem{{1, "first", ""}, {2, "second", ""}, {3, "third", ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+}
+
+type updateUser struct {
+	ID    int    `db:"id"`
+	Name  string `db:"name"`
+	Email string `db:"email"`
+}
+
+func TestBuildUpdateQuery(t *testing.T) {
+	fields, _ := structFields(updateUser{ID: 7, Name: "Ann", Email: "ann@example.com"})
+
+	query, args, err := buildUpdateQuery("users", fields, "id")
+	if err != nil {
+		t.Fatalf("buildUpdateQuery() error = %v", err)
+	}
+	if want := `UPDATE "users" SET "name" = $1, "email" = $2 WHERE "id" = $3`; query != want {
+		t.Fatalf("buildUpdateQuery() = %s, want %s", query, want)
+	}
+	if want := []any{"Ann", "ann@example.com", 7}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("buildUpdateQuery() args = %v, want %v", args, want)
+	}
+}
+
+func TestBuildUpdateQueryErrors(t *testing.T) {
+	fields, _ := structFields(updateUser{})
+	if _, _, err := buildUpdateQuery("users", fields, "missing"); err == nil {
+		t.Error("buildUpdateQuery() error = nil for a key without a field")
+	}
+
+	keyOnly, _ := structFields(struct {
+		ID int `db:"id"`
+	}{})
+	if _, _, err := buildUpdateQuery("users", keyOnly, "id"); err == nil {
+		t.Error("buildUpdateQuery() error = nil without columns to update")
+	}
+}
+
+func TestUpdateStructReturnsAffected(t *testing.T) {
+	db := &fakeQuerier{affected: 1}
+
+	n, err := UpdateStruct(context.Background(), db, "users", &updateUser{ID: 7, Name: "Ann"}, "id")
+	if err != nil {
+		t.Fatalf("UpdateStruct() error = %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("UpdateStruct() = %d, want 1", n)
+	}
+
+	if _, err = UpdateStruct(context.Background(), db, "users", "not a struct", "id"); err == nil {
+		t.Fatal("UpdateStruct() error = nil for a non-struct value")
+	}
+}
+
+func TestUpdateStruct(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY, name text, email text, created_at timestamptz NOT NULL DEFAULT '2020-01-01'")
+	mustExec(t, pool, "INSERT INTO "+table+" (id, name, email) VALUES (1, 'a', 'a@example.com'), (2, 'b', 'b@example.com')")
+
+	n, err := UpdateStruct(ctx, pool, table, updateUser{ID: 1, Name: "Ann", Email: "ann@example.com"}, "id")
+	if err != nil {
+		t.Fatalf("UpdateStruct() error = %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("UpdateStruct() = %d, want 1", n)
+	}
+
+	got, err := QueryStructs[updateUser](ctx, pool, "SELECT id, name, email FROM "+table+" ORDER BY id")
+	if err != nil {
+		t.Fatalf("QueryStructs() error = %v", err)
+	}
+	want := []updateUser{{1, "Ann", "ann@example.com"}, {2, "b", "b@example.com"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+
+	untouched, err := Count(ctx, pool, "SELECT 1 FROM "+table+" WHERE created_at = '2020-01-01'")
+	if err != nil || untouched != 2 {
+		t.Fatalf("rows with the original created_at = %d, %v, want 2", untouched, err)
+	}
+}
+
+func TestBulkUpsertStructsChunks(t *testing.T) {
+	type item struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	rows := make([]item, maxQueryParams/2+1)
+	for i := range rows {
+		rows[i] = item{ID: i, Name: "n"}
+	}
+
+	db := &fakeQuerier{affected: 5}
+	total, err := BulkUpsertStructs(context.Background(), db, "items", rows, []string{"id"}, []string{"name"})
+	if err != nil {
+		t.Fatalf("BulkUpsertStructs() error = %v", err)
+	}
+	if total != 10 {
+		t.Fatalf("BulkUpsertStructs() = %d, want 10", total)
+	}
+
+	if len(db.calls) != 4 || db.calls[0].sql != "BEGIN" || db.calls[3].sql != "COMMIT" {
+		t.Fatalf("statements = %d calls, want BEGIN, two chunks and COMMIT", len(db.calls))
+	}
+	if got := len(db.calls[1].args); got != maxQueryParams-1 {
+		t.Fatalf("first chunk has %d params, want %d", got, maxQueryParams-1)
+	}
+	last := db.calls[2]
+	want := `INSERT INTO "items" ("id","name") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`
+	if last.sql != want || !reflect.DeepEqual(last.args, []any{len(rows) - 1, "n"}) {
+		t.Fatalf("last chunk = %q %v, want %q", last.sql, last.args, want)
+	}
+}
+
+func TestBulkUpsertStructsRollsBack(t *testing.T) {
+	type item struct {
+		ID int `db:"id"`
+	}
+
+	db := &fakeQuerier{err: errors.New("broken"), failOn: `INSERT INTO "items" ("id") VALUES ($1) ON CONFLICT ("id") DO NOTHING`}
+	if _, err := BulkUpsertStructs(context.Background(), db, "items", []item{{ID: 1}}, []string{"id"}, nil); err == nil {
+		t.Fatal("BulkUpsertStructs() error = nil, want the insert error")
+	}
+	if got := db.statements(); got[len(got)-1] != "ROLLBACK" {
+		t.Fatalf("statements = %q, want a ROLLBACK", got)
+	}
+
+	if _, err := BulkUpsertStructs[item](context.Background(), &fakeQuerier{}, "items", nil, []string{"id"}, nil); err == nil {
+		t.Fatal("BulkUpsertStructs() error = nil for no rows")
+	}
+}
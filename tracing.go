@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sync/atomic"
+	"time"
+)
+
+var tracer atomic.Pointer[trace.Tracer]
+
+// SetTracer включает трассировку запросов через OpenTelemetry, nil отключает ее.
+// Без трассировщика хелперы не создают span и не тратят на это ресурсы
+func SetTracer(t trace.Tracer) {
+	if t == nil {
+		tracer.Store(nil)
+		return
+	}
+
+	tracer.Store(&t)
+}
+
+func noopEndSpan(error) {}
+
+// startSpan начинает span операции op, если задан трассировщик, и возвращает функцию его завершения
+func startSpan(ctx context.Context, op, statement string) (context.Context, func(err error)) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, noopEndSpan
+	}
+
+	ctx, span := (*t).Start(ctx, op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", op),
+			attribute.String("db.statement", statement),
+		),
+	)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		span.SetAttributes(attribute.Int64("db.duration_ms", time.Since(start).Milliseconds()))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
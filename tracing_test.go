@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"sync"
+	"testing"
+)
+
+// recordingSpan span, запоминающий имя, атрибуты, статус и завершение
+type recordingSpan struct {
+	noop.Span
+	mu     sync.Mutex
+	name   string
+	attrs  map[attribute.Key]attribute.Value
+	status codes.Code
+	errs   []error
+	ended  bool
+}
+
+func (s *recordingSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordingSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errs = append(s.errs, err)
+}
+
+func (s *recordingSpan) SetStatus(code codes.Code, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+}
+
+func (s *recordingSpan) End(...trace.SpanEndOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+// recordingTracer трассировщик, собирающий начатые span
+type recordingTracer struct {
+	noop.Tracer
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &recordingSpan{name: name, attrs: make(map[attribute.Key]attribute.Value)}
+	cfg := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(cfg.Attributes()...)
+
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// useTracer включает трассировщик на время теста
+func useTracer(t *testing.T) *recordingTracer {
+	t.Helper()
+
+	tr := &recordingTracer{}
+	SetTracer(tr)
+	t.Cleanup(func() { SetTracer(nil) })
+
+	return tr
+}
+
+func TestTracingSpanAroundQuery(t *testing.T) {
+	tr := useTracer(t)
+	db := &fakeQuerier{affected: 1}
+
+	if err := Exec(context.Background(), db, "UPDATE users SET name = $1", "Ann"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("started %d spans, want 1", len(tr.spans))
+	}
+
+	span := tr.spans[0]
+	if span.name != "Exec" || !span.ended {
+		t.Fatalf("span %q ended = %v, want Exec ended", span.name, span.ended)
+	}
+	if got := span.attrs["db.statement"].AsString(); got != "UPDATE users SET name = $1" {
+		t.Fatalf("db.statement = %q", got)
+	}
+	if got := span.attrs["db.system"].AsString(); got != "postgresql" {
+		t.Fatalf("db.system = %q, want postgresql", got)
+	}
+	if _, ok := span.attrs["db.duration_ms"]; !ok {
+		t.Fatal("span has no db.duration_ms")
+	}
+	if span.status == codes.Error {
+		t.Fatal("successful query has error status")
+	}
+}
+
+func TestTracingSpanRecordsError(t *testing.T) {
+	tr := useTracer(t)
+	queryErr := errors.New("syntax error")
+
+	_ = Exec(context.Background(), &fakeQuerier{err: queryErr}, "UPDATE")
+
+	if len(tr.spans) != 1 {
+		t.Fatalf("started %d spans, want 1", len(tr.spans))
+	}
+	span := tr.spans[0]
+	if span.status != codes.Error || len(span.errs) != 1 || !errors.Is(span.errs[0], queryErr) {
+		t.Fatalf("span status = %v, errors = %v, want error status with %v", span.status, span.errs, queryErr)
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	SetTracer(nil)
+
+	ctx := context.Background()
+	spanCtx, end := startSpan(ctx, "Exec", "SELECT 1")
+	end(nil)
+
+	if spanCtx != ctx {
+		t.Fatal("startSpan() changed the context without a tracer")
+	}
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+)
+
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout задает statement_timeout для транзакций, открываемых хелперами с этим контекстом
+// (WithTransaction, RequestInOneTransaction и другие): сервер отменит запрос дольше timeout с SQLSTATE 57014
+func WithStatementTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, timeout)
+}
+
+func statementTimeout(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return timeout, ok && timeout > 0
+}
+
+// WithTransaction выполняет fn в транзакции: фиксирует ее, если fn вернула nil, иначе (и при панике) откатывает.
+// Внутри fn хелперы библиотеки можно вызывать с tx вместо пула, например QueryStructs[T](ctx, tx, ...)
+func WithTransaction(ctx context.Context, db Querier, fn func(tx pgx.Tx) error) (err error) {
+	ctx, finish, err := startQuery(ctx, db, "WithTransaction", "BEGIN")
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed transaction in %s", elapsed)
+	}()
+
+	tx, err := beginTransaction(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return runInTx(ctx, tx, fn)
+}
+
+// WithSavepoint выполняет fn внутри точки сохранения транзакции tx: при ошибке fn откатывается только
+// сделанное в fn, а внешняя транзакция продолжает работу
+func WithSavepoint(ctx context.Context, tx pgx.Tx, fn func(tx pgx.Tx) error) error {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	return runInTx(ctx, savepoint, fn)
+}
+
+// QueryWithStatementTimeout выполняет QueryStructs в отдельной транзакции с SET LOCAL statement_timeout,
+// так что длительность запроса ограничивает сам сервер
+func QueryWithStatementTimeout[T any](ctx context.Context, db Querier, timeout time.Duration, sql string, args ...any) ([]T, error) {
+	var result []T
+	err := WithTransaction(WithStatementTimeout(ctx, timeout), db, func(tx pgx.Tx) error {
+		var err error
+		result, err = QueryStructs[T](ctx, tx, sql, args...)
+		return err
+	})
+
+	return result, err
+}
+
+// QueryStructsForUpdate выполняет QueryStructs в транзакции tx, добавляя к запросу FOR UPDATE, а со skipLocked -
+// FOR UPDATE SKIP LOCKED, чтобы параллельные обработчики очереди не забирали одни и те же строки.
+// Блокировки держатся до завершения tx
+func QueryStructsForUpdate[T any](ctx context.Context, tx pgx.Tx, sql string, skipLocked bool, args ...any) ([]T, error) {
+	sql = trimStatement(sql) + "\nFOR UPDATE"
+	if skipLocked {
+		sql += " SKIP LOCKED"
+	}
+
+	return QueryStructs[T](ctx, tx, sql, args...)
+}
@@ -0,0 +1,311 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithSavepointRollsBackOnlyInnerWork(t *testing.T) {
+	db := &fakeQuerier{}
+	errRisky := errors.New("risky statement failed")
+
+	err := WithTransaction(context.Background(), db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(context.Background(), "INSERT outer"); err != nil {
+			return err
+		}
+
+		err := WithSavepoint(context.Background(), tx, func(sp pgx.Tx) error {
+			_, _ = sp.Exec(context.Background(), "INSERT inner")
+			return errRisky
+		})
+		if !errors.Is(err, errRisky) {
+			t.Fatalf("WithSavepoint() error = %v, want %v", err, errRisky)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+
+	want := []string{"BEGIN", "INSERT outer", "SAVEPOINT", "INSERT inner", "ROLLBACK", "COMMIT"}
+	if got := db.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestWithSavepoint(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY")
+
+	err := WithTransaction(ctx, pool, func(tx pgx.Tx) error {
+		mustExec(t, tx, "INSERT INTO "+table+" VALUES (1)")
+
+		err := WithSavepoint(ctx, tx, func(sp pgx.Tx) error {
+			mustExec(t, sp, "INSERT INTO "+table+" VALUES (2)")
+			_, err := sp.Exec(ctx, "INSERT INTO "+table+" VALUES (1)")
+			return err
+		})
+		if !IsUniqueViolation(err) {
+			t.Fatalf("WithSavepoint() error = %v, want a unique violation", err)
+		}
+
+		mustExec(t, tx, "INSERT INTO "+table+" VALUES (3)")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+
+	ids, err := QuerySimple[int](ctx, pool, "SELECT id FROM "+table+" ORDER BY id")
+	if err != nil {
+		t.Fatalf("QuerySimple() error = %v", err)
+	}
+	if want := []int{1, 3}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("committed ids = %v, want %v", ids, want)
+	}
+}
+
+func TestHelpersRunInsideTransaction(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"id"}, rows: [][]any{{1}}}
+	errAbort := errors.New("abort")
+
+	err := WithTransaction(context.Background(), db, func(tx pgx.Tx) error {
+		if err := Exec(context.Background(), tx, "INSERT INTO users (id) VALUES ($1)", 1); err != nil {
+			return err
+		}
+		if _, err := QuerySimple[int](context.Background(), tx, "SELECT id FROM users"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTransaction() error = %v, want %v", err, errAbort)
+	}
+
+	want := []string{"BEGIN", "INSERT INTO users (id) VALUES ($1)", "SELECT id FROM users", "ROLLBACK"}
+	if got := db.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestHelpersParticipateInRollback(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY, name text")
+	errAbort := errors.New("abort")
+
+	type row struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	err := WithTransaction(ctx, pool, func(tx pgx.Tx) error {
+		if err := BulkInsert(ctx, tx, table, []string{"id", "name"}, [][]any{{1, "a"}, {2, "b"}}); err != nil {
+			return err
+		}
+
+		rows, err := QueryStructs[row](ctx, tx, "SELECT id, name FROM "+table+" ORDER BY id")
+		if err != nil {
+			return err
+		}
+		if len(rows) != 2 {
+			t.Errorf("QueryStructs() inside tx = %v, want the 2 inserted rows", rows)
+		}
+
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("WithTransaction() error = %v, want %v", err, errAbort)
+	}
+
+	n, err := Count(ctx, pool, "SELECT * FROM "+table)
+	if err != nil {
+		t.Fatalf("Count() error = %v", err)
+	}
+	if n != 0 {
+		t.Fatalf("%d rows survived the rollback", n)
+	}
+}
+
+func TestRequestInOneTransactionBatchRollsBackOnError(t *testing.T) {
+	db := &fakeQuerier{failOn: "INSERT bad", err: errors.New("bad statement")}
+
+	err := RequestInOneTransactionBatch(context.Background(), db, map[string][]any{"INSERT bad": nil})
+	if err == nil {
+		t.Fatal("RequestInOneTransactionBatch() error = nil")
+	}
+
+	want := []string{"BEGIN", "INSERT bad", "ROLLBACK"}
+	if got := db.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestRequestInOneTransactionBatch(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY")
+
+	err := RequestInOneTransactionBatch(ctx, pool, map[string][]any{
+		"INSERT INTO " + table + " VALUES ($1)":       {1},
+		"INSERT INTO " + table + " VALUES ($1), ($2)": {2, 3},
+	})
+	if err != nil {
+		t.Fatalf("RequestInOneTransactionBatch() error = %v", err)
+	}
+	if n, err := Count(ctx, pool, "SELECT * FROM "+table); err != nil || n != 3 {
+		t.Fatalf("Count() = %d, %v, want 3", n, err)
+	}
+
+	err = RequestInOneTransactionBatch(ctx, pool, map[string][]any{
+		"INSERT INTO " + table + " VALUES ($1)":       {4},
+		"INSERT INTO " + table + " VALUES ($1), ($2)": {5, 1},
+	})
+	if !IsUniqueViolation(err) {
+		t.Fatalf("RequestInOneTransactionBatch() error = %v, want a unique violation", err)
+	}
+	if n, err := Count(ctx, pool, "SELECT * FROM "+table+" WHERE id > 3"); err != nil || n != 0 {
+		t.Fatalf("Count() after failed batch = %d, %v, want 0", n, err)
+	}
+}
+
+// benchmarkTransaction измеряет run на statements независимых вставках в одной транзакции
+func benchmarkTransaction(b *testing.B, statements int, run func(context.Context, Querier, map[string][]any) error) {
+	pool := testPool(b)
+	ctx := context.Background()
+	table := testTable(b, pool, "id int")
+
+	queries := make(map[string][]any, statements)
+	for i := 0; i < statements; i++ {
+		queries[fmt.Sprintf("INSERT INTO %s SELECT $1::int + %d", table, i)] = []any{i}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := run(ctx, pool, queries); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRequestInOneTransaction(b *testing.B) {
+	benchmarkTransaction(b, 50, RequestInOneTransaction)
+}
+
+func BenchmarkRequestInOneTransactionBatch(b *testing.B) {
+	benchmarkTransaction(b, 50, RequestInOneTransactionBatch)
+}
+
+func TestWithStatementTimeoutSetsLocalTimeout(t *testing.T) {
+	db := &fakeQuerier{}
+	ctx := WithStatementTimeout(context.Background(), 1500*time.Millisecond)
+
+	if err := WithTransaction(ctx, db, func(tx pgx.Tx) error { return nil }); err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+
+	want := []string{"BEGIN", "SET LOCAL statement_timeout = 1500", "COMMIT"}
+	if got := db.statements(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements = %q, want %q", got, want)
+	}
+}
+
+func TestWithStatementTimeoutIgnoresNonPositive(t *testing.T) {
+	db := &fakeQuerier{}
+	ctx := WithStatementTimeout(context.Background(), 0)
+
+	if err := WithTransaction(ctx, db, func(tx pgx.Tx) error { return nil }); err != nil {
+		t.Fatalf("WithTransaction() error = %v", err)
+	}
+	if want := []string{"BEGIN", "COMMIT"}; !reflect.DeepEqual(db.statements(), want) {
+		t.Fatalf("statements = %q, want %q", db.statements(), want)
+	}
+}
+
+func TestQueryWithStatementTimeoutCanceledByServer(t *testing.T) {
+	pool := testPool(t)
+
+	_, err := QueryWithStatementTimeout[struct {
+		Done string `db:"done"`
+	}](context.Background(), pool, 100*time.Millisecond, "SELECT pg_sleep(2)::text AS done")
+
+	pgErr, ok := AsPgError(err)
+	if !ok || pgErr.Code != "57014" {
+		t.Fatalf("QueryWithStatementTimeout() error = %v, want SQLSTATE 57014", err)
+	}
+}
+
+func TestQueryStructsForUpdateAppendsLockClause(t *testing.T) {
+	type job struct {
+		ID int `db:"id"`
+	}
+	ctx := context.Background()
+
+	db := &fakeQuerier{columns: []string{"id"}, rows: [][]any{{1}}}
+	tx := &fakeTx{q: db}
+
+	if _, err := QueryStructsForUpdate[job](ctx, tx, "SELECT id FROM jobs WHERE state = $1 LIMIT 10;", false, "new"); err != nil {
+		t.Fatalf("QueryStructsForUpdate() error = %v", err)
+	}
+	got, err := QueryStructsForUpdate[job](ctx, tx, "SELECT id FROM jobs -- next batch", true)
+	if err != nil {
+		t.Fatalf("QueryStructsForUpdate() skip locked error = %v", err)
+	}
+	if want := []job{{ID: 1}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryStructsForUpdate() = %+v, want %+v", got, want)
+	}
+
+	want := []string{
+		"SELECT id FROM jobs WHERE state = $1 LIMIT 10\nFOR UPDATE",
+		"SELECT id FROM jobs\nFOR UPDATE SKIP LOCKED",
+	}
+	if stmts := db.statements(); !reflect.DeepEqual(stmts, want) {
+		t.Fatalf("statements = %q, want %q", stmts, want)
+	}
+}
+
+func TestQueryStructsForUpdateSkipsLockedRows(t *testing.T) {
+	type job struct {
+		ID int `db:"id"`
+	}
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY")
+	mustExec(t, pool, "INSERT INTO "+table+" SELECT generate_series(1, 4)")
+
+	first, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	defer first.Rollback(ctx)
+
+	locked, err := QueryStructsForUpdate[job](ctx, first, "SELECT id FROM "+table+" ORDER BY id LIMIT 2", true)
+	if err != nil {
+		t.Fatalf("QueryStructsForUpdate() error = %v", err)
+	}
+
+	second, err := pool.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	defer second.Rollback(ctx)
+
+	rest, err := QueryStructsForUpdate[job](ctx, second, "SELECT id FROM "+table+" ORDER BY id", true)
+	if err != nil {
+		t.Fatalf("QueryStructsForUpdate() error = %v", err)
+	}
+
+	if want := []job{{ID: 1}, {ID: 2}}; !reflect.DeepEqual(locked, want) {
+		t.Fatalf("first worker got %+v, want %+v", locked, want)
+	}
+	if want := []job{{ID: 3}, {ID: 4}}; !reflect.DeepEqual(rest, want) {
+		t.Fatalf("second worker got %+v, want %+v", rest, want)
+	}
+}
@@ -0,0 +1,146 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"reflect"
+	"time"
+)
+
+// ConflictTarget цель ON CONFLICT: список колонок (в том числе составной ключ) или имя ограничения.
+// Where задает предикат частичного уникального индекса и применяется только вместе с Columns
+type ConflictTarget struct {
+	Columns    []string
+	Constraint string
+	Where      string
+}
+
+// clause возвращает цель конфликта с ведущим пробелом или пустую строку, если цель не задана
+func (t ConflictTarget) clause() (string, error) {
+	switch {
+	case t.Constraint != "" && len(t.Columns) > 0:
+		return "", fmt.Errorf("conflict target must have either columns or a constraint, not both")
+	case t.Constraint != "":
+		if t.Where != "" {
+			return "", fmt.Errorf("conflict predicate cannot be used with a named constraint")
+		}
+		name, err := sanitizeIdentifier(t.Constraint)
+		if err != nil {
+			return "", err
+		}
+		return " ON CONSTRAINT " + name, nil
+	case len(t.Columns) > 0:
+		cols, err := sanitizeIdentifiers(t.Columns)
+		if err != nil {
+			return "", err
+		}
+		if t.Where != "" {
+			return fmt.Sprintf(" (%s) WHERE %s", cols, t.Where), nil
+		}
+		return fmt.Sprintf(" (%s)", cols), nil
+	case t.Where != "":
+		return "", fmt.Errorf("conflict predicate requires conflict columns")
+	}
+
+	return "", nil
+}
+
+// Upsert вставляет строку, а при конфликте по conflictColumns обновляет updateColumns значениями из EXCLUDED.
+// Если updateColumns пуст, конфликтующая строка пропускается (DO NOTHING)
+func Upsert(ctx context.Context, db Querier, tableName string, columns []string, values []any, conflictColumns []string, updateColumns []string) error {
+	return UpsertOn(ctx, db, tableName, columns, values, ConflictTarget{Columns: conflictColumns}, updateColumns)
+}
+
+// UpsertOn то же, что Upsert, но цель конфликта задается через ConflictTarget:
+// составной ключ, именованное ограничение (ON CONFLICT ON CONSTRAINT) или частичный индекс с WHERE
+func UpsertOn(ctx context.Context, db Querier, tableName string, columns []string, values []any, target ConflictTarget, updateColumns []string) (err error) {
+	ctx, finish, err := startQuery(ctx, db, "Upsert", "INSERT INTO "+tableName)
+	if err != nil {
+		return err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed upsert to the %s in %s", tableName, elapsed)
+	}()
+
+	if len(columns) != len(values) {
+		return fmt.Errorf("columns count %d does not match values count %d", len(columns), len(values))
+	}
+
+	query, err := buildUpsertQuery(tableName, columns, target, updateColumns)
+	if err != nil {
+		return err
+	}
+
+	if _, err = db.Exec(ctx, query, values...); err != nil {
+		return fmt.Errorf("upsert failed: %w", err)
+	}
+
+	return nil
+}
+
+// upsertInsertedColumn имя служебной колонки RETURNING, признака вставки
+const upsertInsertedColumn = "upsert_inserted__"
+
+// UpsertReturning выполняет upsert одной строки и возвращает итоговую строку из RETURNING returning
+// (например, "*" или "id, created_at") и признак того, что строка была вставлена, а не обновлена (xmax = 0).
+// Для структуры T колонки сопоставляются как в QueryStructsLax. При DO NOTHING и конфликте строка не возвращается
+// и результатом будет pgx.ErrNoRows
+func UpsertReturning[T any](ctx context.Context, db Querier, tableName string, columns []string, values []any, target ConflictTarget, updateColumns []string, returning string) (_ T, inserted bool, err error) {
+	ctx, finish, err := startQuery(ctx, db, "UpsertReturning", "INSERT INTO "+tableName)
+	if err != nil {
+		return *new(T), false, err
+	}
+	defer func() { err = finish(err) }()
+
+	start := time.Now()
+	defer func() {
+		elapsed := time.Since(start)
+		logTiming(ctx, err, "Executed upsert to the %s in %s", tableName, elapsed)
+	}()
+
+	if len(columns) != len(values) {
+		return *new(T), false, fmt.Errorf("columns count %d does not match values count %d", len(columns), len(values))
+	}
+
+	query, err := buildUpsertQuery(tableName, columns, target, updateColumns)
+	if err != nil {
+		return *new(T), false, err
+	}
+	query += fmt.Sprintf(" RETURNING %s, (xmax = 0) AS %s", returning, upsertInsertedColumn)
+
+	rows, err := db.Query(ctx, query, values...)
+	if err != nil {
+		return *new(T), false, fmt.Errorf("upsert failed: %w", err)
+	}
+	defer rows.Close()
+
+	result, err := pgx.CollectOneRow(rows, func(row pgx.CollectableRow) (T, error) {
+		if !isRowStruct(reflect.TypeFor[T]()) {
+			var t T
+			return t, row.Scan(&t, &inserted)
+		}
+
+		t, err := rowToStructMapped[T](nil, true)(row)
+		if err != nil {
+			return t, err
+		}
+
+		values, err := row.Values()
+		if err != nil {
+			return t, err
+		}
+		inserted, _ = values[len(values)-1].(bool)
+
+		return t, nil
+	})
+	if err != nil {
+		return *new(T), false, err
+	}
+
+	return result, inserted, nil
+}
@@ -0,0 +1,288 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"reflect"
+	"testing"
+)
+
+func TestBuildUpsertQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		conflict      []string
+		updateColumns []string
+		want          string
+	}{
+		{
+			name:          "do update",
+			conflict:      []string{"id"},
+			updateColumns: []string{"name"},
+			want:          `INSERT INTO "users" ("id","name") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			name:     "do nothing",
+			conflict: []string{"id"},
+			want:     `INSERT INTO "users" ("id","name") VALUES ($1,$2) ON CONFLICT ("id") DO NOTHING`,
+		},
+		{
+			name: "do nothing on any conflict",
+			want: `INSERT INTO "users" ("id","name") VALUES ($1,$2) ON CONFLICT DO NOTHING`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildUpsertQuery("users", []string{"id", "name"}, ConflictTarget{Columns: tt.conflict}, tt.updateColumns)
+			if err != nil {
+				t.Fatalf("buildUpsertQuery() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("buildUpsertQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildUpsertQueryErrors(t *testing.T) {
+	if _, err := buildUpsertQuery("users", nil, ConflictTarget{}, nil); err == nil {
+		t.Error("buildUpsertQuery() error = nil for no columns")
+	}
+	if _, err := buildUpsertQuery("users", []string{"id", "name"}, ConflictTarget{}, []string{"name"}); err == nil {
+		t.Error("buildUpsertQuery() error = nil for DO UPDATE without conflict columns")
+	}
+}
+
+func TestUpsertValuesCountMismatch(t *testing.T) {
+	err := Upsert(context.Background(), nil, "users", []string{"id", "name"}, []any{1}, []string{"id"}, nil)
+	if err == nil {
+		t.Fatal("Upsert() error = nil for mismatched columns and values")
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY, name text NOT NULL")
+
+	columns := []string{"id", "name"}
+	if err := Upsert(ctx, pool, table, columns, []any{1, "first"}, []string{"id"}, []string{"name"}); err != nil {
+		t.Fatalf("Upsert() insert error = %v", err)
+	}
+	if err := Upsert(ctx, pool, table, columns, []any{1, "second"}, []string{"id"}, []string{"name"}); err != nil {
+		t.Fatalf("Upsert() update error = %v", err)
+	}
+	if err := Upsert(ctx, pool, table, columns, []any{1, "ignored"}, []string{"id"}, nil); err != nil {
+		t.Fatalf("Upsert() do nothing error = %v", err)
+	}
+
+	name, err := QueryOne[string](ctx, pool, "SELECT name FROM "+table+" WHERE id = 1")
+	if err != nil {
+		t.Fatalf("QueryOne() error = %v", err)
+	}
+	if name != "second" {
+		t.Fatalf("name = %q, want second", name)
+	}
+}
+
+func TestBulkUpsertNoValues(t *testing.T) {
+	err := BulkUpsert(context.Background(), nil, "users", []string{"id"}, nil, []string{"id"}, nil)
+	if err == nil {
+		t.Fatal("BulkUpsert() error = nil for empty values")
+	}
+}
+
+func TestBulkUpsert(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY, name text NOT NULL")
+	mustExec(t, pool, "INSERT INTO "+table+" VALUES (1, 'old'), (2, 'old')")
+
+	columns := []string{"id", "name"}
+	err := BulkUpsert(ctx, pool, table, columns, [][]any{{1, "skipped"}, {3, "new"}}, []string{"id"}, nil)
+	if err != nil {
+		t.Fatalf("BulkUpsert() do nothing error = %v", err)
+	}
+
+	err = BulkUpsert(ctx, pool, table, columns, [][]any{{2, "updated"}, {4, "new"}}, []string{"id"}, []string{"name"})
+	if err != nil {
+		t.Fatalf("BulkUpsert() do update error = %v", err)
+	}
+
+	type row struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+	got, err := QueryStructs[row](ctx, pool, "SELECT id, name FROM "+table+" ORDER BY id")
+	if err != nil {
+		t.Fatalf("QueryStructs() error = %v", err)
+	}
+
+	want := []row{{1, "old"}, {2, "updated"}, {3, "new"}, {4, "new"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+}
+
+func TestConflictTargetClause(t *testing.T) {
+	tests := []struct {
+		name   string
+		target ConflictTarget
+		want   string
+	}{
+		{"none", ConflictTarget{}, ""},
+		{"composite key", ConflictTarget{Columns: []string{"tenant_id", "email"}}, ` ("tenant_id","email")`},
+		{"named constraint", ConflictTarget{Constraint: "users_email_key"}, ` ON CONSTRAINT "users_email_key"`},
+		{"partial index", ConflictTarget{Columns: []string{"email"}, Where: "deleted_at IS NULL"}, ` ("email") WHERE deleted_at IS NULL`},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.target.clause()
+		if err != nil {
+			t.Fatalf("%s: clause() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: clause() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConflictTargetClauseErrors(t *testing.T) {
+	tests := map[string]ConflictTarget{
+		"columns and constraint": {Columns: []string{"id"}, Constraint: "users_pkey"},
+		"constraint with where":  {Constraint: "users_pkey", Where: "active"},
+		"where without columns":  {Where: "active"},
+		"invalid constraint":     {Constraint: `users"pkey`},
+	}
+
+	for name, target := range tests {
+		if _, err := target.clause(); err == nil {
+			t.Errorf("%s: clause() error = nil", name)
+		}
+	}
+}
+
+func TestUpsertOnCompositeKeyAndConstraint(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "tenant_id int, email text, name text")
+	mustExec(t, pool, "ALTER TABLE "+table+" ADD CONSTRAINT "+table+"_key UNIQUE (tenant_id, email)")
+
+	columns := []string{"tenant_id", "email", "name"}
+	composite := ConflictTarget{Columns: []string{"tenant_id", "email"}}
+	named := ConflictTarget{Constraint: table + "_key"}
+
+	steps := []struct {
+		values []any
+		target ConflictTarget
+	}{
+		{[]any{1, "a@example.com", "first"}, composite},
+		{[]any{2, "a@example.com", "other tenant"}, composite},
+		{[]any{1, "a@example.com", "by columns"}, composite},
+		{[]any{2, "a@example.com", "by constraint"}, named},
+	}
+	for _, s := range steps {
+		if err := UpsertOn(ctx, pool, table, columns, s.values, s.target, []string{"name"}); err != nil {
+			t.Fatalf("UpsertOn(%v) error = %v", s.values, err)
+		}
+	}
+
+	names, err := QuerySimple[string](ctx, pool, "SELECT name FROM "+table+" ORDER BY tenant_id")
+	if err != nil {
+		t.Fatalf("QuerySimple() error = %v", err)
+	}
+	if want := []string{"by columns", "by constraint"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+}
+
+func TestUpsertOnPartialIndex(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "email text, name text, deleted bool NOT NULL DEFAULT false")
+	mustExec(t, pool, "CREATE UNIQUE INDEX ON "+table+" (email) WHERE NOT deleted")
+	mustExec(t, pool, "INSERT INTO "+table+" (email, name, deleted) VALUES ('a@example.com', 'deleted', true)")
+
+	target := ConflictTarget{Columns: []string{"email"}, Where: "NOT deleted"}
+	for _, name := range []string{"first", "second"} {
+		if err := UpsertOn(ctx, pool, table, []string{"email", "name"}, []any{"a@example.com", name}, target, []string{"name"}); err != nil {
+			t.Fatalf("UpsertOn(%s) error = %v", name, err)
+		}
+	}
+
+	names, err := QuerySimple[string](ctx, pool, "SELECT name FROM "+table+" ORDER BY deleted")
+	if err != nil {
+		t.Fatalf("QuerySimple() error = %v", err)
+	}
+	if want := []string{"second", "deleted"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("names = %v, want %v", names, want)
+	}
+}
+
+func TestUpsertReturningStruct(t *testing.T) {
+	type user struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	db := &fakeQuerier{columns: []string{"id", "name", "created_at", upsertInsertedColumn}, rows: [][]any{{1, "Ann", nil, true}}}
+	got, inserted, err := UpsertReturning[user](context.Background(), db, "users", []string{"id", "name"}, []any{1, "Ann"},
+		ConflictTarget{Columns: []string{"id"}}, []string{"name"}, "*")
+	if err != nil {
+		t.Fatalf("UpsertReturning() error = %v", err)
+	}
+	if want := (user{ID: 1, Name: "Ann"}); got != want || !inserted {
+		t.Fatalf("UpsertReturning() = %+v, %v, want %+v, true", got, inserted, want)
+	}
+
+	want := `INSERT INTO "users" ("id","name") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name" RETURNING *, (xmax = 0) AS upsert_inserted__`
+	if stmts := db.statements(); len(stmts) != 1 || stmts[0] != want {
+		t.Fatalf("statements = %q, want %q", stmts, want)
+	}
+}
+
+func TestUpsertReturningScalar(t *testing.T) {
+	db := &fakeQuerier{columns: []string{"id", upsertInsertedColumn}, rows: [][]any{{7, false}}}
+	id, inserted, err := UpsertReturning[int](context.Background(), db, "users", []string{"email"}, []any{"a@example.com"},
+		ConflictTarget{Columns: []string{"email"}}, []string{"email"}, "id")
+	if err != nil {
+		t.Fatalf("UpsertReturning() error = %v", err)
+	}
+	if id != 7 || inserted {
+		t.Fatalf("UpsertReturning() = %d, %v, want 7, false", id, inserted)
+	}
+}
+
+func TestUpsertReturningOnServer(t *testing.T) {
+	type user struct {
+		ID      int    `db:"id"`
+		Name    string `db:"name"`
+		Version int    `db:"version"`
+	}
+	pool := testPool(t)
+	ctx := context.Background()
+	table := testTable(t, pool, "id int PRIMARY KEY, name text NOT NULL, version int NOT NULL DEFAULT 1, created_at timestamptz DEFAULT now()")
+	target := ConflictTarget{Columns: []string{"id"}}
+
+	got, inserted, err := UpsertReturning[user](ctx, pool, table, []string{"id", "name"}, []any{1, "first"}, target, []string{"name"}, "*")
+	if err != nil {
+		t.Fatalf("UpsertReturning() insert error = %v", err)
+	}
+	if want := (user{ID: 1, Name: "first", Version: 1}); got != want || !inserted {
+		t.Fatalf("UpsertReturning() = %+v, %v, want %+v, true", got, inserted, want)
+	}
+
+	got, inserted, err = UpsertReturning[user](ctx, pool, table, []string{"id", "name"}, []any{1, "second"}, target, []string{"name"}, "*")
+	if err != nil {
+		t.Fatalf("UpsertReturning() update error = %v", err)
+	}
+	if got.Name != "second" || inserted {
+		t.Fatalf("UpsertReturning() = %+v, %v, want the updated row", got, inserted)
+	}
+
+	_, _, err = UpsertReturning[user](ctx, pool, table, []string{"id", "name"}, []any{1, "ignored"}, target, nil, "*")
+	if !errors.Is(err, pgx.ErrNoRows) {
+		t.Fatalf("UpsertReturning() do nothing error = %v, want %v", err, pgx.ErrNoRows)
+	}
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"strconv"
+	"strings"
+)
+
+// WithVector регистрирует на каждом соединении тип vector расширения pgvector, чтобы поля []float32
+// читались из колонок vector и передавались параметрами. Расширение должно быть установлено в базе
+func WithVector() Option {
+	return WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+		var oid uint32
+		if err := conn.QueryRow(ctx, "SELECT 'vector'::regtype::oid").Scan(&oid); err != nil {
+			return fmt.Errorf("failed to load vector type: %w", err)
+		}
+		conn.TypeMap().RegisterType(&pgtype.Type{Name: "vector", OID: oid, Codec: vectorCodec{}})
+
+		return nil
+	})
+}
+
+// QueryNearest возвращает limit строк таблицы, ближайших к query по евклидову расстоянию (оператор <-> pgvector).
+// Требует пул, созданный с опцией WithVector
+func QueryNearest[T any](ctx context.Context, db Querier, tableName, vectorColumn string, query []float32, limit int) ([]T, error) {
+	table, err := sanitizeIdentifier(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	column, err := sanitizeIdentifier(vectorColumn)
+	if err != nil {
+		return nil, err
+	}
+
+	sql := fmt.Sprintf("SELECT * FROM %s ORDER BY %s <-> $1 LIMIT $2", table, column)
+
+	return QueryStructs[T](ctx, db, sql, query, limit)
+}
+
+// vectorCodec кодирует vector в текстовом формате "[1,2,3]" из []float32 и обратно
+type vectorCodec struct{}
+
+func (vectorCodec) FormatSupported(format int16) bool {
+	return format == pgtype.TextFormatCode
+}
+
+func (vectorCodec) PreferredFormat() int16 {
+	return pgtype.TextFormatCode
+}
+
+func (vectorCodec) PlanEncode(_ *pgtype.Map, _ uint32, format int16, value any) pgtype.EncodePlan {
+	if _, ok := value.([]float32); !ok || format != pgtype.TextFormatCode {
+		return nil
+	}
+	return vectorEncodePlan{}
+}
+
+func (vectorCodec) PlanScan(_ *pgtype.Map, _ uint32, format int16, target any) pgtype.ScanPlan {
+	if _, ok := target.(*[]float32); !ok || format != pgtype.TextFormatCode {
+		return nil
+	}
+	return vectorScanPlan{}
+}
+
+func (vectorCodec) DecodeDatabaseSQLValue(_ *pgtype.Map, _ uint32, _ int16, src []byte) (driver.Value, error) {
+	if src == nil {
+		return nil, nil
+	}
+	return string(src), nil
+}
+
+func (vectorCodec) DecodeValue(_ *pgtype.Map, _ uint32, _ int16, src []byte) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+	return parseVector(src)
+}
+
+type vectorEncodePlan struct{}
+
+func (vectorEncodePlan) Encode(value any, buf []byte) ([]byte, error) {
+	v := value.([]float32)
+	if v == nil {
+		return nil, nil
+	}
+
+	buf = append(buf, '[')
+	for i, f := range v {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendFloat(buf, float64(f), 'g', -1, 32)
+	}
+	return append(buf, ']'), nil
+}
+
+type vectorScanPlan struct{}
+
+func (vectorScanPlan) Scan(src []byte, target any) error {
+	dst := target.(*[]float32)
+	if src == nil {
+		*dst = nil
+		return nil
+	}
+
+	v, err := parseVector(src)
+	if err != nil {
+		return err
+	}
+	*dst = v
+	return nil
+}
+
+// parseVector разбирает текстовое представление vector
+func parseVector(src []byte) ([]float32, error) {
+	s := string(src)
+	if len(s) < 2 || s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("invalid vector %q", s)
+	}
+
+	s = s[1 : len(s)-1]
+	if s == "" {
+		return []float32{}, nil
+	}
+
+	parts := strings.Split(s, ",")
+	v := make([]float32, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector element %q: %w", part, err)
+		}
+		v[i] = float32(f)
+	}
+
+	return v, nil
+}
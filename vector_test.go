@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5/pgtype"
+	"reflect"
+	"testing"
+)
+
+// testVectorOID произвольный OID типа vector для тестов кодека без базы
+const testVectorOID = 100000
+
+func TestVectorCodecRoundTrip(t *testing.T) {
+	m := pgtype.NewMap()
+	m.RegisterType(&pgtype.Type{Name: "vector", OID: testVectorOID, Codec: vectorCodec{}})
+
+	buf, err := m.Encode(testVectorOID, pgtype.TextFormatCode, []float32{1, -2.5, 0.125}, nil)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if got, want := string(buf), "[1,-2.5,0.125]"; got != want {
+		t.Fatalf("Encode() = %q, want %q", got, want)
+	}
+
+	var v []float32
+	if err := m.Scan(testVectorOID, pgtype.TextFormatCode, []byte("[1, -2.5,0.125]"), &v); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if want := []float32{1, -2.5, 0.125}; !reflect.DeepEqual(v, want) {
+		t.Fatalf("Scan() = %v, want %v", v, want)
+	}
+
+	if err := m.Scan(testVectorOID, pgtype.TextFormatCode, nil, &v); err != nil || v != nil {
+		t.Fatalf("Scan() of NULL = %v, %v, want nil", v, err)
+	}
+}
+
+func TestParseVector(t *testing.T) {
+	v, err := parseVector([]byte("[]"))
+	if err != nil || len(v) != 0 || v == nil {
+		t.Fatalf("parseVector(\"[]\") = %#v, %v, want an empty vector", v, err)
+	}
+
+	for _, src := range []string{"", "1,2", "[1,2", "[1,x]", "[1,,2]"} {
+		if _, err := parseVector([]byte(src)); err == nil {
+			t.Errorf("parseVector(%q) error = nil", src)
+		}
+	}
+}
+
+func TestQueryNearestBuildsQuery(t *testing.T) {
+	type item struct {
+		ID int `db:"id"`
+	}
+	ctx := context.Background()
+	query := []float32{0.1, 0.2}
+
+	db := &fakeQuerier{columns: []string{"id"}, rows: [][]any{{3}}}
+	got, err := QueryNearest[item](ctx, db, "public.items", "embedding", query, 5)
+	if err != nil {
+		t.Fatalf("QueryNearest() error = %v", err)
+	}
+	if want := []item{{ID: 3}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryNearest() = %+v, want %+v", got, want)
+	}
+
+	want := []fakeCall{{sql: `SELECT * FROM "public"."items" ORDER BY "embedding" <-> $1 LIMIT $2`, args: []any{query, 5}}}
+	if !reflect.DeepEqual(db.calls, want) {
+		t.Fatalf("calls = %v, want %v", db.calls, want)
+	}
+
+	if _, err := QueryNearest[item](ctx, db, "items", `embedding"; DROP TABLE items; --`, query, 5); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Fatalf("QueryNearest() error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+}
+
+func TestQueryNearest(t *testing.T) {
+	type item struct {
+		ID        int       `db:"id"`
+		Embedding []float32 `db:"embedding"`
+	}
+	ctx := context.Background()
+
+	setup := testPool(t)
+	if _, err := setup.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector"); err != nil {
+		t.Skipf("pgvector is not available: %v", err)
+	}
+
+	pool := testPool(t, WithVector())
+	table := testTable(t, pool, "id int, embedding vector(2)")
+	mustExec(t, pool, "INSERT INTO "+table+" VALUES ($1, $2), ($3, $4), ($5, $6)",
+		1, []float32{0, 0}, 2, []float32{1, 1}, 3, []float32{5, 5})
+
+	got, err := QueryNearest[item](ctx, pool, table, "embedding", []float32{0.9, 1.2}, 2)
+	if err != nil {
+		t.Fatalf("QueryNearest() error = %v", err)
+	}
+	if want := []item{{2, []float32{1, 1}}, {1, []float32{0, 0}}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("QueryNearest() = %+v, want %+v", got, want)
+	}
+}